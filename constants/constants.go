@@ -10,15 +10,38 @@ const (
 	CloudFormationAlias   = "cf"
 	CodeBuildService      = "codebuild"
 	EMRService            = "emr"
+	ECRService            = "ecr"
+	ECSService            = "ecs"
+	SSMService            = "ssm"
+	SSMDocumentsService   = "ssm-documents"
 	AllServices           = "all"
 
+	// Pattern names with built-in enrichment
+	AWSClientPattern = "AWS_Client"
+	AWSSecretPattern = "AWS_Secret"
+
 	// Error messages
 	FailedToLoadPatternsError                 = "Failed to load patterns: %v"
+	FailedToLoadFilterError                   = "Failed to load filter: %v"
+	InvalidVerifyEndpointError                = "Invalid -verify-endpoint value: %v"
+	FailedToBufferReportError                 = "Failed to buffer the report: %v"
 	FailedToLoadAWSConfigError                = "Failed to load AWS config: %v"
+	FailedToGetCallerIdentityError            = "Failed to get caller identity, access keys will not be compared to the scanned account: %v"
 	ErrorProcessingServicesError              = "Error processing services: %v"
 	FailedToFetchInstancesError               = "Failed to fetch instances: %w"
 	FailedToFetchLaunchTemplatesError         = "Failed to fetch launch templates: %w"
 	FailedToFetchLambdaFunctionsError         = "Failed to fetch Lambda functions: %w"
+	FailedToFetchLambdaLayersError            = "Failed to fetch Lambda layers: %w"
+	InvalidLambdaVersionsError                = "Invalid -lambda-versions value: %w"
 	FailedToFetchCloudFormationStacksError    = "Failed to fetch CloudFormation stacks: %w"
 	FailedToFetchCloudFormationStackSetsError = "Failed to fetch CloudFormation stack sets: %w"
+	FailedToFetchECRImagesError               = "Failed to fetch ECR images: %w"
+	FailedToFetchECSTaskDefinitionsError      = "Failed to fetch ECS task definitions: %w"
+	FailedToFetchSSMParametersError           = "Failed to fetch SSM parameters: %w"
+	FailedToFetchSSMDocumentsError            = "Failed to fetch SSM documents: %w"
+	FailedToFetchSSMCommandsError             = "Failed to fetch SSM Run Command history: %w"
+	FailedToFetchAccessKeysError              = "Failed to fetch IAM access keys: %v"
+	FailedToFetchGlueTriggersError            = "Failed to fetch Glue triggers: %v"
+	FailedToFetchGlueWorkflowsError           = "Failed to fetch Glue workflows: %v"
+	FailedToFetchGlueConnectionsError         = "Failed to fetch Glue connections: %v"
 )
@@ -0,0 +1,86 @@
+package formatting
+
+import (
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+)
+
+// Finding is a verified active credential printed in the report, kept so it can be listed ahead of the report
+type Finding struct {
+	Resource string
+	Pattern  string
+	Match    string
+}
+
+var (
+	findingsMu       sync.Mutex
+	currentResource  string
+	currentPattern   string
+	criticalFindings []Finding
+)
+
+func setResource(resource string) {
+	findingsMu.Lock()
+	currentResource = resource
+	currentPattern = ""
+	findingsMu.Unlock()
+}
+
+// setPattern remembers the pattern printed last, the matched data printed after it belongs to it
+func setPattern(pattern string) {
+	findingsMu.Lock()
+	currentPattern = pattern
+	findingsMu.Unlock()
+}
+
+// recordMatch keeps the matched data printed under a verified active pattern
+func recordMatch(match string) {
+	findingsMu.Lock()
+	defer findingsMu.Unlock()
+	if isCritical(currentPattern) {
+		criticalFindings = append(criticalFindings, Finding{Resource: currentResource, Pattern: currentPattern, Match: match})
+	}
+}
+
+// CriticalFindings returns the verified active credentials printed so far, in report order
+func CriticalFindings() []Finding {
+	findingsMu.Lock()
+	defer findingsMu.Unlock()
+	return append([]Finding(nil), criticalFindings...)
+}
+
+// BufferReport sends standard output to a temporary file until the returned flush is called, flush prints the
+// verified active credentials first and then the buffered report
+func BufferReport() (func() error, error) {
+	buffer, err := os.CreateTemp("", "awsecrets-report-*")
+	if err != nil {
+		return nil, err
+	}
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = buffer, buffer
+
+	flush := func() error {
+		os.Stdout, color.Output = stdout, colorOutput
+		defer os.Remove(buffer.Name())
+		defer buffer.Close()
+
+		if findings := CriticalFindings(); len(findings) > 0 {
+			criticalColor.Printf("Verified active credentials: %d\n", len(findings))
+			for _, finding := range findings {
+				resourceNameColor.Printf("%s\n", finding.Resource)
+				criticalColor.Printf("Pattern: %s\n", finding.Pattern)
+				matchedDataColor.Printf("Matched Data: %s\n", finding.Match)
+			}
+			stdout.WriteString("\n")
+		}
+		if _, err := buffer.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.Copy(stdout, buffer)
+		return err
+	}
+	return flush, nil
+}
@@ -1,6 +1,8 @@
 package formatting
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
@@ -11,9 +13,11 @@ var (
 	patternNameColor  = color.New(color.FgGreen).Add(color.Bold)
 	matchedDataColor  = color.New(color.FgYellow)
 	detailsColor      = color.New(color.FgHiCyan)
+	criticalColor     = color.New(color.FgRed).Add(color.Bold)
 )
 
 func Title(name string, resource string) {
+	setResource(name + ": " + resource)
 	resourceNameColor.Printf("%s: %s\n", name, resource)
 }
 
@@ -22,6 +26,11 @@ func Data(name string, data string) {
 }
 
 func PatterName(patternName string) {
+	setPattern(patternName)
+	if isCritical(patternName) {
+		criticalColor.Printf("Pattern: %s\n", patternName)
+		return
+	}
 	patternNameColor.Printf("Pattern: %s\n", patternName)
 }
 
@@ -30,8 +39,9 @@ func CloudformationParameter() {
 }
 
 func FuncCodeDetails(section string, matches map[string][]string, showContent bool) {
-	for patternName, matchedStrings := range matches {
-		patternNameColor.Printf("Pattern: %s\n", patternName)
+	for _, patternName := range SortedPatterns(matches) {
+		matchedStrings := matches[patternName]
+		PatterName(patternName)
 		for _, match := range matchedStrings {
 			if showContent {
 				if len(match) > 150 {
@@ -39,17 +49,19 @@ func FuncCodeDetails(section string, matches map[string][]string, showContent bo
 				}
 				matchedDataColor.Printf("Matched Data: %s\n", strings.TrimSpace(match))
 			} else {
-				anonymized := Anonymize(match)
-				if len(anonymized) > 150 {
-					anonymized = anonymized[:147] + "..."
+				match = Anonymize(match)
+				if len(match) > 150 {
+					match = match[:147] + "..."
 				}
-				matchedDataColor.Printf("Matched Data:%s\n", strings.TrimSpace(anonymized))
+				matchedDataColor.Printf("Matched Data:%s\n", strings.TrimSpace(match))
 			}
+			recordMatch(strings.TrimSpace(match))
 		}
 	}
 }
 
 func LambdaFunctionData(functionName string, version string) {
+	setResource(fmt.Sprintf("Lambda Function: %s (Version: %s)", functionName, version))
 	resourceNameColor.Printf("Lambda Function: %s (Version: %s)\n", functionName, version)
 }
 
@@ -59,11 +71,34 @@ func Content(content string, show bool) {
 			content = content[:147] + "..."
 		}
 		matchedDataColor.Printf("Matched Data: %s\n", strings.TrimSpace(content))
+		recordMatch(strings.TrimSpace(content))
 	} else {
 		anonymized := Anonymize(content)
 		if len(anonymized) > 150 {
 			anonymized = anonymized[:147] + "..."
 		}
 		matchedDataColor.Printf("Matched Data: %s\n", strings.TrimSpace(anonymized))
+		recordMatch(strings.TrimSpace(anonymized))
 	}
 }
+
+// SortedPatterns orders pattern names alphabetically with verified active credentials first, BufferReport lists
+// them ahead of the whole report
+func SortedPatterns(matches map[string][]string) []string {
+	names := make([]string, 0, len(matches))
+	for name := range matches {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := isCritical(names[i]), isCritical(names[j])
+		if ci != cj {
+			return ci
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func isCritical(patternName string) bool {
+	return strings.Contains(patternName, "[CRITICAL")
+}
@@ -0,0 +1,79 @@
+package formatting
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+func TestSortedPatterns(t *testing.T) {
+	matches := map[string][]string{
+		"b":                                      nil,
+		"a":                                      nil,
+		"z [CRITICAL: verified active]":          nil,
+		"c [verified inactive]":                  nil,
+		"AWS_Client [CRITICAL: verified active]": nil,
+	}
+	want := []string{"AWS_Client [CRITICAL: verified active]", "z [CRITICAL: verified active]", "a", "b", "c [verified inactive]"}
+	if got := SortedPatterns(matches); !reflect.DeepEqual(got, want) {
+		t.Errorf("SortedPatterns() = %v, want %v", got, want)
+	}
+}
+
+func TestBufferReport(t *testing.T) {
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = out, out, true
+	defer func() {
+		os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor
+		criticalFindings = nil
+	}()
+
+	flush, err := BufferReport()
+	if err != nil {
+		t.Fatalf("BufferReport() error = %v", err)
+	}
+	Title("Lambda Function", "first")
+	FuncCodeDetails("", map[string][]string{"rule": {"not verified"}}, true)
+	fmt.Println()
+	Title("Glue Job", "second")
+	FuncCodeDetails("", map[string][]string{"AWS_Client [CRITICAL: verified active]": {"AKIAVERIFIED"}}, true)
+	fmt.Println()
+	Title("EC2 Instance", "third")
+	PatterName("AWS_Client [CRITICAL: verified active]")
+	Content("AKIAUSERDATA", true)
+	PatterName("rule")
+	Content("not verified", true)
+	if err := flush(); err != nil {
+		t.Fatalf("flush() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := string(data)
+	want := "Verified active credentials: 2\n" +
+		"Glue Job: second\n" +
+		"Pattern: AWS_Client [CRITICAL: verified active]\n" +
+		"Matched Data: AKIAVERIFIED\n" +
+		"EC2 Instance: third\n" +
+		"Pattern: AWS_Client [CRITICAL: verified active]\n" +
+		"Matched Data: AKIAUSERDATA\n\n" +
+		"Lambda Function: first\n"
+	if !strings.HasPrefix(report, want) {
+		t.Errorf("report starts with %q, want %q", report, want)
+	}
+	if os.Stdout != out || color.Output != out {
+		t.Error("flush() did not restore standard output")
+	}
+}
@@ -0,0 +1,82 @@
+package pattern
+
+import (
+	"encoding/base32"
+	"fmt"
+	"strings"
+)
+
+// Annotator returns extra labels for a match, e.g. the account that owns an access key
+type Annotator func(match string) []string
+
+// AccountIDFromAccessKey decodes the AWS account ID embedded in an access key ID (AKIA/ASIA...) without calling AWS
+func AccountIDFromAccessKey(keyID string) (string, error) {
+	if len(keyID) != 20 {
+		return "", fmt.Errorf("invalid access key ID length: %d", len(keyID))
+	}
+	decoded, err := base32.StdEncoding.DecodeString(keyID[4:])
+	if err != nil {
+		return "", fmt.Errorf("invalid access key ID %s: %w", keyID[:4], err)
+	}
+
+	var z uint64
+	for _, b := range decoded[:6] {
+		z = z<<8 | uint64(b)
+	}
+	return fmt.Sprintf("%012d", (z&0x7fffffffff80)>>7), nil
+}
+
+// AccessKeyIDs returns the AWS access key IDs contained in a match
+func AccessKeyIDs(match string) []string {
+	return awsKeyIDPattern.FindAllString(match, -1)
+}
+
+// AccountAnnotator labels AWS access key matches with their owning account and whether it is the scanned one
+func AccountAnnotator(scannedAccountID string) Annotator {
+	return func(match string) []string {
+		var labels []string
+		seen := make(map[string]bool)
+		for _, keyID := range awsKeyIDPattern.FindAllString(match, -1) {
+			accountID, err := AccountIDFromAccessKey(keyID)
+			if err != nil || seen[accountID] {
+				continue
+			}
+			seen[accountID] = true
+
+			owner := "foreign account"
+			switch scannedAccountID {
+			case "":
+				owner = "scanned account unknown"
+			case accountID:
+				owner = "scanned account"
+			}
+			labels = append(labels, fmt.Sprintf("owner account %s (%s)", accountID, owner))
+		}
+		return labels
+	}
+}
+
+// AddAnnotator registers an annotator for the given pattern name
+func (p *Patterns) AddAnnotator(name string, annotator Annotator) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.annotators == nil {
+		p.annotators = make(map[string][]Annotator)
+	}
+	p.annotators[name] = append(p.annotators[name], annotator)
+}
+
+func (p *Patterns) annotate(name string, match string) string {
+	p.mu.Lock()
+	annotators := p.annotators[name]
+	p.mu.Unlock()
+
+	var labels []string
+	for _, annotator := range annotators {
+		labels = append(labels, annotator(match)...)
+	}
+	if len(labels) == 0 {
+		return ""
+	}
+	return strings.Join(labels, "; ")
+}
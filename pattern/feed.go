@@ -7,10 +7,30 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 type Patterns struct {
-	Compiled map[string]*regexp.Regexp
+	Compiled   map[string]*regexp.Regexp
+	Validators map[string]ruleValidator
+	Verifiers  map[string]Verifier
+	Targets    map[string]string
+	Filter     *Filter
+
+	mu                 sync.Mutex
+	validationFailures map[string]int
+	annotators         map[string][]Annotator
+	verify             bool
+	verified           map[string]string
+}
+
+// rule is the object form of a pattern file entry, the plain string form only carries the regex
+type rule struct {
+	Regex      string        `json:"regex"`
+	Validator  string        `json:"validator"`
+	Validation string        `json:"validation"`
+	Verify     *HTTPVerifier `json:"verify"`
+	Target     string        `json:"target"`
 }
 
 func LoadPatterns(filename string) (*Patterns, error) {
@@ -26,13 +46,25 @@ func LoadPatterns(filename string) (*Patterns, error) {
 	defer file.Close()
 
 	decoder := json.NewDecoder(file)
-	patternStrings := make(map[string]string)
-	if err := decoder.Decode(&patternStrings); err != nil {
+	entries := make(map[string]json.RawMessage)
+	if err := decoder.Decode(&entries); err != nil {
 		return nil, err
 	}
 
 	compiled := make(map[string]*regexp.Regexp)
-	for name, patternStr := range patternStrings {
+	validators := make(map[string]ruleValidator)
+	verifiers := make(map[string]Verifier)
+	targets := make(map[string]string)
+	for name, entry := range entries {
+		var r rule
+		if err := json.Unmarshal(entry, &r.Regex); err != nil {
+			if err := json.Unmarshal(entry, &r); err != nil {
+				log.Printf("Invalid pattern entry for %s: %v", name, err)
+				continue
+			}
+		}
+		patternStr := r.Regex
+
 		pattern, err := regexp.Compile(patternStr)
 		if err != nil {
 			if name == "Password Pattern" {
@@ -48,9 +80,40 @@ func LoadPatterns(filename string) (*Patterns, error) {
 			}
 		}
 		compiled[name] = pattern
+
+		if r.Verify != nil {
+			verifiers[name] = r.Verify
+		}
+
+		switch r.Target {
+		case TargetContent:
+		case TargetLiteral, TargetSensitiveLiteral:
+			targets[name] = r.Target
+		default:
+			log.Printf("Unknown target %s for %s, matching against content", r.Target, name)
+		}
+
+		if r.Validator != "" {
+			validate, ok := Validators[r.Validator]
+			if !ok {
+				log.Printf("Unknown validator %s for %s, reporting without validation", r.Validator, name)
+				continue
+			}
+			validators[name] = ruleValidator{
+				Name:     r.Validator,
+				Validate: validate,
+				Require:  r.Validation != ValidationMark,
+			}
+		}
 	}
 
-	return &Patterns{Compiled: compiled}, nil
+	return &Patterns{
+		Compiled:           compiled,
+		Validators:         validators,
+		Verifiers:          verifiers,
+		Targets:            targets,
+		validationFailures: make(map[string]int),
+	}, nil
 }
 
 // - [ ] Password logic (not implemented yet)
@@ -81,17 +144,33 @@ func (p *Patterns) ValidatePassword(password string) bool {
 }
 
 func (p *Patterns) MatchPatterns(userInput string, matchMode string) map[string][]string {
+	return p.matchPatterns(userInput, matchMode, nil)
+}
+
+// MatchPatternsExcept scans the input with the pattern set without the named rules
+func (p *Patterns) MatchPatternsExcept(userInput string, matchMode string, rules []string) map[string][]string {
+	skip := make(map[string]bool, len(rules))
+	for _, name := range rules {
+		skip[name] = true
+	}
+	return p.matchPatterns(userInput, matchMode, skip)
+}
+
+func (p *Patterns) matchPatterns(userInput string, matchMode string, skip map[string]bool) map[string][]string {
 	matches := make(map[string][]string)
 	blocklist := map[string][]string{
 		"AWS_Client": {"iam:PassRole", "S3Key"},
 	}
 
 	for name, pattern := range p.Compiled {
+		if skip[name] || p.Targets[name] != TargetContent {
+			continue
+		}
 		if name == "Password Pattern" {
 			lines := regexp.MustCompile(`\r?\n`).Split(userInput, -1)
 			for _, line := range lines {
 				if p.ValidatePassword(line) {
-					matches[name] = append(matches[name], line)
+					p.record(matches, name, nil, line, userInput)
 				}
 			}
 		} else {
@@ -109,7 +188,7 @@ func (p *Patterns) MatchPatterns(userInput string, matchMode string) map[string]
 						}
 					}
 					if !blocked {
-						matches[name] = append(matches[name], matchedString)
+						p.record(matches, name, pattern, matchedString, userInput)
 					}
 				}
 			} else if matchMode == "MatchString" {
@@ -126,7 +205,7 @@ func (p *Patterns) MatchPatterns(userInput string, matchMode string) map[string]
 							}
 						}
 						if !blocked {
-							matches[name] = append(matches[name], line)
+							p.record(matches, name, pattern, line, userInput)
 						}
 					}
 				}
@@ -135,3 +214,45 @@ func (p *Patterns) MatchPatterns(userInput string, matchMode string) map[string]
 	}
 	return matches
 }
+
+// record stores a match after passing it through the reference/placeholder filter
+func (p *Patterns) record(matches map[string][]string, name string, pattern *regexp.Regexp, match string, input string) {
+	reportedName, keep := p.Filter.apply(name, pattern, match)
+	if !keep {
+		return
+	}
+
+	if validator, ok := p.Validators[name]; ok {
+		keep, verified := validator.check(match)
+		if !keep {
+			p.mu.Lock()
+			p.validationFailures[name]++
+			p.mu.Unlock()
+			return
+		}
+		if verified {
+			reportedName = withLabel(reportedName, "verified format")
+		}
+	}
+
+	if label := p.annotate(name, match); label != "" {
+		reportedName = withLabel(reportedName, label)
+	}
+
+	if label := p.verifyMatch(name, pattern, match, input); label != "" {
+		reportedName = withLabel(reportedName, label)
+	}
+
+	matches[reportedName] = append(matches[reportedName], match)
+}
+
+// ValidationFailures returns, per pattern, how many matches were dropped because their validator failed
+func (p *Patterns) ValidationFailures() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	result := make(map[string]int, len(p.validationFailures))
+	for name, count := range p.validationFailures {
+		result[name] = count
+	}
+	return result
+}
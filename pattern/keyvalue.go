@@ -0,0 +1,269 @@
+package pattern
+
+import (
+	"bufio"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StructuredSecretRule is the name key/value findings are reported under
+const StructuredSecretRule = "Structured Secret"
+
+// Formats understood by ParseKeyValues
+const (
+	FormatEnv        = "env"
+	FormatProperties = "properties"
+	FormatINI        = "ini"
+	FormatYAML       = "yaml"
+	FormatJSON       = "json"
+)
+
+// KeyValue is a leaf of a structured document with its full key path, e.g. database.primary.password
+type KeyValue struct {
+	Path  string
+	Value string
+	Line  int
+}
+
+var (
+	sensitiveKeyPattern     = regexp.MustCompile(`(^|_)([a-z0-9]*passw(or)?d|pwd|secrets?|tokens?|credentials?|dsn|(api|access|private|auth|secret)_?key|conn(ection)?_?str(ing)?)$`)
+	camelBoundaryPattern    = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+	keySeparatorPattern     = regexp.MustCompile(`[^a-z0-9]+`)
+	numberPattern           = regexp.MustCompile(`^[+\-]?[0-9]+(\.[0-9]+)?$`)
+	connectionStringPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.\-]*://[^:/@\s]+:[^@\s]+@`)
+	assignmentPattern       = regexp.MustCompile(`^\s*(?:export\s+)?([A-Za-z0-9_.\-/]+)\s*(=|:)\s*(.*)$`)
+	sectionPattern          = regexp.MustCompile(`^\s*\[([^\[\]]+)\]\s*$`)
+	keyNameValuePattern     = regexp.MustCompile(`^[a-z_.\-]+$`)
+)
+
+// minSecretLength skips one to three character values such as "x" or "" + "x" concatenation pieces
+const minSecretLength = 4
+
+// DetectFormat picks a format from the file extension, falling back to sniffing the content
+func DetectFormat(name string, content string) string {
+	base := strings.ToLower(path.Base(name))
+	switch {
+	case base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env"):
+		return FormatEnv
+	}
+	switch strings.ToLower(path.Ext(base)) {
+	case ".properties":
+		return FormatProperties
+	case ".ini", ".cfg", ".conf", ".cnf":
+		return FormatINI
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	case ".py", ".js", ".ts", ".java", ".go", ".sh", ".scala", ".rb", ".jar", ".class", ".pyc":
+		return ""
+	}
+	return sniffFormat(content)
+}
+
+func sniffFormat(content string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return ""
+	}
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[{") {
+		return FormatJSON
+	}
+
+	var lines, assignments, sections int
+	scanner := bufio.NewScanner(strings.NewReader(trimmed))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		lines++
+		if sectionPattern.MatchString(line) {
+			sections++
+		} else if m := assignmentPattern.FindStringSubmatch(line); m != nil && m[2] == "=" {
+			assignments++
+		}
+	}
+	switch {
+	case lines == 0:
+		return ""
+	case sections > 0 && sections+assignments == lines:
+		return FormatINI
+	case assignments == lines:
+		return FormatEnv
+	}
+
+	var doc map[string]interface{}
+	if strings.HasPrefix(trimmed, "---") || strings.Contains(trimmed, ":\n") {
+		if err := yaml.Unmarshal([]byte(trimmed), &doc); err == nil && len(doc) > 0 {
+			return FormatYAML
+		}
+	}
+	return ""
+}
+
+// ParseKeyValues walks a .env, properties, INI, YAML or JSON document as key/value pairs
+func ParseKeyValues(name string, content string) []KeyValue {
+	switch DetectFormat(name, content) {
+	case FormatEnv, FormatProperties:
+		return parseAssignments(content, false)
+	case FormatINI:
+		return parseAssignments(content, true)
+	case FormatYAML, FormatJSON:
+		var root yaml.Node
+		if err := yaml.Unmarshal([]byte(content), &root); err != nil {
+			return nil
+		}
+		var pairs []KeyValue
+		walkNode(&root, "", &pairs)
+		return pairs
+	}
+	return nil
+}
+
+func parseAssignments(content string, sections bool) []KeyValue {
+	var pairs []KeyValue
+	var section string
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		if sections {
+			if m := sectionPattern.FindStringSubmatch(line); m != nil {
+				section = strings.TrimSpace(m[1])
+				continue
+			}
+		}
+		m := assignmentPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key := m[1]
+		if section != "" {
+			key = section + "." + key
+		}
+		pairs = append(pairs, KeyValue{Path: key, Value: unquote(m[3]), Line: lineNumber})
+	}
+	return pairs
+}
+
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+func walkNode(node *yaml.Node, prefix string, pairs *[]KeyValue) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkNode(child, prefix, pairs)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			walkNode(node.Content[i+1], key, pairs)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			walkNode(child, prefix+"["+strconv.Itoa(i)+"]", pairs)
+		}
+	case yaml.AliasNode:
+		walkNode(node.Alias, prefix, pairs)
+	case yaml.ScalarNode:
+		*pairs = append(*pairs, KeyValue{Path: prefix, Value: node.Value, Line: node.Line})
+	}
+}
+
+// IsSensitiveKey applies the key-name heuristics to the last segment of a key path, or to the last two when the
+// last one is only "key", as in fs.s3a.secret.key or fs.s3a.access.key
+func IsSensitiveKey(keyPath string) bool {
+	keyPath = strings.TrimRight(keyPath, "]0123456789[")
+	leaf := keyPath
+	if i := strings.LastIndexAny(keyPath, "./"); i >= 0 && i+1 < len(keyPath) {
+		leaf = keyPath[i+1:]
+		if strings.EqualFold(leaf, "key") {
+			if j := strings.LastIndexAny(keyPath[:i], "./"); j >= 0 {
+				leaf = keyPath[j+1:]
+			} else {
+				leaf = keyPath
+			}
+		}
+	}
+	if keyPath == "PWD" {
+		// The shell's working directory, not a password
+		return false
+	}
+	return sensitiveKeyPattern.MatchString(keyTokens(leaf))
+}
+
+// keyTokens lowercases a key and joins its words with underscores, so the heuristics match whole words:
+// "dbPassword" and "db-password" give "db_password", "token_ttl" ends in "ttl" rather than "token"
+func keyTokens(key string) string {
+	key = strings.ToLower(camelBoundaryPattern.ReplaceAllString(key, "${1}_${2}"))
+	return strings.TrimRight(strings.Trim(keySeparatorPattern.ReplaceAllString(key, "_"), "_"), "0123456789")
+}
+
+// plausibleSecret skips values the key-name heuristics should not report: short values, booleans, numbers and
+// key names such as "password"
+func plausibleSecret(value string) bool {
+	value = strings.TrimSpace(value)
+	if len(value) < minSecretLength || numberPattern.MatchString(value) {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "yes", "no", "on", "off", "null", "none":
+		return false
+	}
+	return !(keyNameValuePattern.MatchString(value) && IsSensitiveKey(value))
+}
+
+// MatchKeyValues reports values assigned to password-like keys, or holding connection strings with credentials,
+// under "Structured Secret (<name>: <key path>, line N)"
+func (p *Patterns) MatchKeyValues(name string, content string) map[string][]string {
+	matches := make(map[string][]string)
+	for _, kv := range ParseKeyValues(name, content) {
+		location := kv.Path
+		if name != "" {
+			location = name + ": " + kv.Path
+		}
+		p.recordKeyValue(matches, fmt.Sprintf("%s, line %d", location, kv.Line), kv.Path, kv.Value)
+	}
+	return matches
+}
+
+// MatchKeyValue applies the same heuristics to a single pair such as an environment variable or a job argument,
+// reported under "Structured Secret (<location>)"
+func (p *Patterns) MatchKeyValue(location string, key string, value string) map[string][]string {
+	matches := make(map[string][]string)
+	p.recordKeyValue(matches, location, key, value)
+	return matches
+}
+
+func (p *Patterns) recordKeyValue(matches map[string][]string, location string, key string, value string) {
+	if value == "" {
+		return
+	}
+	if !(IsSensitiveKey(key) && plausibleSecret(value)) && !connectionStringPattern.MatchString(value) {
+		return
+	}
+	reportedName, keep := p.Filter.apply(StructuredSecretRule, nil, value)
+	if !keep {
+		return
+	}
+	label := fmt.Sprintf("%s (%s)%s", StructuredSecretRule, location, strings.TrimPrefix(reportedName, StructuredSecretRule))
+	matches[label] = append(matches[label], value)
+}
@@ -0,0 +1,208 @@
+package pattern
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    string
+	}{
+		{"dotenv", ".env", "", FormatEnv},
+		{"dotenv suffix", "config/.env.production", "", FormatEnv},
+		{"properties", "application.properties", "", FormatProperties},
+		{"ini", "my.cnf", "", FormatINI},
+		{"yaml", "config.yml", "", FormatYAML},
+		{"json", "settings.json", "", FormatJSON},
+		{"source code", "app.py", "PASSWORD = 'x'", ""},
+		{"sniffed json", "config", `{"a": 1}`, FormatJSON},
+		{"sniffed ini", "config", "[db]\nuser=admin\npassword=x\n", FormatINI},
+		{"sniffed env", "config", "# comment\nA=1\nB=2\n", FormatEnv},
+		{"sniffed yaml", "config", "db:\n  password: x\n", FormatYAML},
+		{"plain text", "README", "just some text", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectFormat(tt.file, tt.content); got != tt.want {
+				t.Errorf("DetectFormat(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    []KeyValue
+	}{
+		{
+			name:    "env with export and quotes",
+			file:    ".env",
+			content: "# comment\nexport DB_PASSWORD=\"Hunter2\"\nAPI_KEY='abc'\n\nDEBUG=true\n",
+			want: []KeyValue{
+				{Path: "DB_PASSWORD", Value: "Hunter2", Line: 2},
+				{Path: "API_KEY", Value: "abc", Line: 3},
+				{Path: "DEBUG", Value: "true", Line: 5},
+			},
+		},
+		{
+			name:    "properties with colon separator",
+			file:    "app.properties",
+			content: "! comment\nspring.datasource.password: Hunter2\n",
+			want:    []KeyValue{{Path: "spring.datasource.password", Value: "Hunter2", Line: 2}},
+		},
+		{
+			name:    "ini sections",
+			file:    "credentials.ini",
+			content: "[default]\naws_secret_access_key = abc\n; comment\n[prod]\npassword=x\n",
+			want: []KeyValue{
+				{Path: "default.aws_secret_access_key", Value: "abc", Line: 2},
+				{Path: "prod.password", Value: "x", Line: 5},
+			},
+		},
+		{
+			name:    "yaml nested and lists",
+			file:    "config.yaml",
+			content: "database:\n  primary:\n    password: Hunter2\n  hosts:\n    - a\n    - b\n",
+			want: []KeyValue{
+				{Path: "database.primary.password", Value: "Hunter2", Line: 3},
+				{Path: "database.hosts[0]", Value: "a", Line: 5},
+				{Path: "database.hosts[1]", Value: "b", Line: 6},
+			},
+		},
+		{
+			name:    "json",
+			file:    "config.json",
+			content: "{\n  \"db\": {\"token\": \"abc\"}\n}\n",
+			want:    []KeyValue{{Path: "db.token", Value: "abc", Line: 2}},
+		},
+		{
+			name:    "invalid yaml",
+			file:    "broken.yaml",
+			content: "a: [\n",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseKeyValues(tt.file, tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseKeyValues() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsSensitiveKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"DB_PASSWORD", true},
+		{"database.primary.password", true},
+		{"spring.datasource.pwd", true},
+		{"GITHUB_TOKEN", true},
+		{"api-key", true},
+		{"connectionString", true},
+		{"secrets[2]", true},
+		{"db/password", true},
+		{"password.length", false},
+		{"database.host", false},
+		{"items[0]", false},
+		{"key", false},
+		{"fs.s3a.secret.key", true},
+		{"fs.s3a.access.key", true},
+		{"fs.s3a.bucket.logs.secret.key", true},
+		{"fs.s3a.encryption.key", false},
+		{"spark.hadoop.fs.s3a.secret.key", true},
+		{"dbPassword", true},
+		{"client_secret", true},
+		{"require_password", true},
+		{"password_min_length", false},
+		{"token_ttl", false},
+		{"secret_name", false},
+		{"PWD", false},
+		{"OLDPWD", false},
+		{"tokenizer", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := IsSensitiveKey(tt.key); got != tt.want {
+				t.Errorf("IsSensitiveKey(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchKeyValue(t *testing.T) {
+	p := &Patterns{}
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		want  map[string][]string
+	}{
+		{"sensitive key", "DB_PASSWORD", "Hunter2", map[string][]string{"Structured Secret (loc)": {"Hunter2"}}},
+		{"connection string", "DATABASE_URL", "postgres://admin:Hunter2@db:5432/app", map[string][]string{"Structured Secret (loc)": {"postgres://admin:Hunter2@db:5432/app"}}},
+		{"plain value", "STAGE", "prod", map[string][]string{}},
+		{"empty value", "DB_PASSWORD", "", map[string][]string{}},
+		{"boolean", "require_password", "false", map[string][]string{}},
+		{"number", "password_min_length", "8", map[string][]string{}},
+		{"ttl", "token_ttl", "3600", map[string][]string{}},
+		{"secret name", "secret_name", "prod/db", map[string][]string{}},
+		{"working directory", "PWD", "/app", map[string][]string{}},
+		{"short value", "DB_PASSWORD", "abc", map[string][]string{}},
+		{"key name as value", "DB_PASSWORD", "password", map[string][]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.MatchKeyValue("loc", tt.key, tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchKeyValue() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchKeyValues(t *testing.T) {
+	filter, err := NewFilter(FilterInfo, "")
+	if err != nil {
+		t.Fatalf("NewFilter() error = %v", err)
+	}
+	p := &Patterns{Filter: filter}
+	got := p.MatchKeyValues("app/.env", "DB_PASSWORD=Hunter2\nAPI_TOKEN=${TOKEN}\nSTAGE=prod\n")
+	want := map[string][]string{
+		"Structured Secret (app/.env: DB_PASSWORD, line 1)":                          {"Hunter2"},
+		"Structured Secret (app/.env: API_TOKEN, line 2) [informational: reference]": {"${TOKEN}"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("MatchKeyValues() = %v, want %v", got, want)
+	}
+}
+
+func TestPlausibleSecret(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"Hunter2", true},
+		{"abc", false},
+		{"false", false},
+		{"Off", false},
+		{"3600", false},
+		{"-1.5", false},
+		{"db_password", false},
+		{"2024-05-01", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := plausibleSecret(tt.value); got != tt.want {
+				t.Errorf("plausibleSecret(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package pattern
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// LiteralSupersededRules are line rules replaced by the literal lexers when scanning source files of a supported language
+var LiteralSupersededRules = []string{"Password Usage"}
+
+// Languages supported by the literal lexers
+const (
+	LanguagePython     = "python"
+	LanguageJavaScript = "javascript"
+	LanguageJava       = "java"
+	LanguageGo         = "go"
+)
+
+// Rule targets, set with "target" in the pattern file
+const (
+	TargetContent          = ""
+	TargetLiteral          = "literal"
+	TargetSensitiveLiteral = "sensitive_literal"
+)
+
+// Literal is a string literal found in source code, Ident is the identifier or key it is assigned to, if any
+type Literal struct {
+	Line  int
+	Ident string
+	Value string
+}
+
+type sourceToken struct {
+	kind string // ident, string, op
+	text string
+	line int
+}
+
+// LanguageForFile picks a lexer from the file extension
+func LanguageForFile(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".py":
+		return LanguagePython
+	case ".js", ".mjs", ".cjs", ".jsx", ".ts", ".tsx":
+		return LanguageJavaScript
+	case ".java":
+		return LanguageJava
+	case ".go":
+		return LanguageGo
+	}
+	return ""
+}
+
+// ExtractLiterals lexes the source and returns its string literals with the identifiers they are assigned to
+func ExtractLiterals(language string, content string) []Literal {
+	tokens := lexSource(language, content)
+
+	var literals []Literal
+	for i, token := range tokens {
+		if token.kind != "string" {
+			continue
+		}
+		literals = append(literals, Literal{Line: token.line, Ident: assignedIdent(tokens, i), Value: token.text})
+	}
+	return literals
+}
+
+// assignedIdent looks behind a string literal for "ident =", "ident :=", "key:", "key =>" or a ("key", value) call
+func assignedIdent(tokens []sourceToken, i int) string {
+	if i < 2 {
+		return ""
+	}
+	op, target := tokens[i-1], tokens[i-2]
+	switch op.text {
+	case "=", ":=", ":", "=>", "+=":
+		if target.kind == "ident" || target.kind == "string" {
+			return target.text
+		}
+	case ",":
+		if target.kind == "string" && i >= 3 && tokens[i-3].text == "(" {
+			return target.text
+		}
+	}
+	return ""
+}
+
+func lexSource(language string, content string) []sourceToken {
+	var tokens []sourceToken
+	runes := []rune(content)
+	line := 1
+	hashComments := language == LanguagePython
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '\n':
+			line++
+		case unicode.IsSpace(r):
+		case hashComments && r == '#':
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case !hashComments && r == '/' && i+1 < len(runes) && runes[i+1] == '/':
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case !hashComments && r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i < len(runes) && !(runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/'); i++ {
+				if runes[i] == '\n' {
+					line++
+				}
+			}
+			i++
+		case r == '"' || r == '\'' || r == '`':
+			startLine := line
+			value, end, lines := lexString(language, runes, i)
+			line += lines
+			i = end
+			if r == '\'' && (language == LanguageGo || language == LanguageJava) {
+				// rune and char literals
+				continue
+			}
+			tokens = append(tokens, sourceToken{kind: "string", text: value, line: startLine})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			word := string(runes[start : i+1])
+			// Python string prefixes: r"", b"", f"", rb""...
+			if language == LanguagePython && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\'') && len(word) <= 2 &&
+				strings.Trim(strings.ToLower(word), "rbuf") == "" {
+				continue
+			}
+			tokens = append(tokens, sourceToken{kind: "ident", text: word, line: line})
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case ":=", "=>", "==", "!=", "<=", ">=", "+=":
+					op = two
+					i++
+				}
+			}
+			tokens = append(tokens, sourceToken{kind: "op", text: op, line: line})
+		}
+	}
+	return tokens
+}
+
+// lexString reads a string literal starting at runes[start], returning its value, last index and newlines consumed
+func lexString(language string, runes []rune, start int) (string, int, int) {
+	quote := runes[start]
+	var value strings.Builder
+	lines := 0
+
+	// Triple quoted strings (Python) and text blocks (Java)
+	triple := (language == LanguagePython || language == LanguageJava && quote == '"') &&
+		start+2 < len(runes) && runes[start+1] == quote && runes[start+2] == quote
+	raw := quote == '`' && language == LanguageGo
+
+	i := start + 1
+	if triple {
+		i = start + 3
+	}
+	for ; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\n' {
+			lines++
+			if !triple && quote != '`' {
+				// Unterminated single line string
+				return value.String(), i - 1, lines - 1
+			}
+		}
+		if r == '\\' && !raw && i+1 < len(runes) {
+			i++
+			if runes[i] == '\n' {
+				lines++
+				continue
+			}
+			value.WriteRune(runes[i])
+			continue
+		}
+		if r == quote {
+			if !triple {
+				return value.String(), i, lines
+			}
+			if i+2 < len(runes) && runes[i+1] == quote && runes[i+2] == quote {
+				return value.String(), i + 2, lines
+			}
+		}
+		value.WriteRune(r)
+	}
+	return value.String(), len(runes) - 1, lines
+}
+
+// MatchSourceLiterals applies the rules targeting literals, sensitive_literal rules only to literals assigned to
+// password-like identifiers, under "<rule> (<file>:<line>, <ident>)"
+func (p *Patterns) MatchSourceLiterals(name string, content string) map[string][]string {
+	matches := make(map[string][]string)
+	language := LanguageForFile(name)
+	if language == "" {
+		return matches
+	}
+
+	for _, literal := range ExtractLiterals(language, content) {
+		if literal.Value == "" {
+			continue
+		}
+		sensitive := literal.Ident != "" && IsSensitiveKey(literal.Ident) && plausibleSecret(literal.Value)
+		location := fmt.Sprintf("%s:%d", name, literal.Line)
+		if literal.Ident != "" {
+			location += ", " + literal.Ident
+		}
+
+		for ruleName, target := range p.Targets {
+			if target == TargetSensitiveLiteral && !sensitive {
+				continue
+			}
+			if re := p.Compiled[ruleName]; re != nil && re.MatchString(literal.Value) {
+				p.recordLiteral(matches, ruleName, re, location, literal.Value, content)
+			}
+		}
+	}
+	return matches
+}
+
+// recordLiteral passes a literal through the filter, validator, annotator and verifier and labels it with its location
+func (p *Patterns) recordLiteral(matches map[string][]string, rule string, re *regexp.Regexp, location string, value string, input string) {
+	recorded := make(map[string][]string)
+	p.record(recorded, rule, re, value, input)
+	for reportedName, values := range recorded {
+		label := fmt.Sprintf("%s (%s)%s", rule, location, strings.TrimPrefix(reportedName, rule))
+		matches[label] = append(matches[label], values...)
+	}
+}
@@ -0,0 +1,142 @@
+package pattern
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLanguageForFile(t *testing.T) {
+	tests := []struct {
+		file string
+		want string
+	}{
+		{"app.py", LanguagePython},
+		{"src/index.mjs", LanguageJavaScript},
+		{"handler.TS", LanguageJavaScript},
+		{"Main.java", LanguageJava},
+		{"main.go", LanguageGo},
+		{"run.sh", ""},
+		{"config.yaml", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := LanguageForFile(tt.file); got != tt.want {
+				t.Errorf("LanguageForFile(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractLiterals(t *testing.T) {
+	tests := []struct {
+		name     string
+		language string
+		content  string
+		want     []Literal
+	}{
+		{
+			name:     "python assignments, dicts and comments",
+			language: LanguagePython,
+			content:  "# password = 'commented'\nDB_PASSWORD = \"Hunter2\"\nconfig = {'api_key': r'abc'}\nconnect(\"token\", 'xyz')\n",
+			want: []Literal{
+				{Line: 2, Ident: "DB_PASSWORD", Value: "Hunter2"},
+				{Line: 3, Ident: "", Value: "api_key"},
+				{Line: 3, Ident: "api_key", Value: "abc"},
+				{Line: 4, Ident: "", Value: "token"},
+				{Line: 4, Ident: "token", Value: "xyz"},
+			},
+		},
+		{
+			name:     "python triple quoted string",
+			language: LanguagePython,
+			content:  "key = '''line1\nline2'''\nsecret = 'after'\n",
+			want: []Literal{
+				{Line: 1, Ident: "key", Value: "line1\nline2"},
+				{Line: 3, Ident: "secret", Value: "after"},
+			},
+		},
+		{
+			name:     "javascript escapes, templates and comments",
+			language: LanguageJavaScript,
+			content:  "// const password = 'x'\n/* token: 'y' */\nconst password = 'it\\'s';\nconst url = `https://x`;\n",
+			want: []Literal{
+				{Line: 3, Ident: "password", Value: "it's"},
+				{Line: 4, Ident: "url", Value: "https://x"},
+			},
+		},
+		{
+			name:     "go short declarations, raw strings and runes",
+			language: LanguageGo,
+			content:  "token := `raw\\n`\nsep := ','\nvar secret = \"s\"\n",
+			want: []Literal{
+				{Line: 1, Ident: "token", Value: "raw\\n"},
+				{Line: 3, Ident: "secret", Value: "s"},
+			},
+		},
+		{
+			name:     "java map put and chars",
+			language: LanguageJava,
+			content:  "char c = 'a';\nprops.put(\"password\", \"Hunter2\");\n",
+			want: []Literal{
+				{Line: 2, Ident: "", Value: "password"},
+				{Line: 2, Ident: "password", Value: "Hunter2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExtractLiterals(tt.language, tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractLiterals() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSourceLiterals(t *testing.T) {
+	patternMatcher, err := LoadPatterns("content.json")
+	if err != nil {
+		t.Fatalf("LoadPatterns() error = %v", err)
+	}
+	patternMatcher.Filter, err = NewFilter(FilterInfo, "")
+	if err != nil {
+		t.Fatalf("NewFilter() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    map[string][]string
+	}{
+		{
+			name:    "password identifier",
+			file:    "app/db.py",
+			content: "DB_PASSWORD = 'Hunter2!'\n",
+			want:    map[string][]string{"Hardcoded Literal (app/db.py:1, DB_PASSWORD)": {"Hunter2!"}},
+		},
+		{
+			name:    "placeholder goes through the filter",
+			file:    "app/db.py",
+			content: "DB_PASSWORD = '<changeme>'\n",
+			want:    map[string][]string{"Hardcoded Literal (app/db.py:1, DB_PASSWORD) [informational: placeholder]": {"<changeme>"}},
+		},
+		{
+			name:    "key names and short values are skipped",
+			file:    "app/db.py",
+			content: "PASSWORD_FIELD = 'db_password'\npassword = 'x'\nname = 'Hunter2!'\n",
+			want:    map[string][]string{},
+		},
+		{
+			name:    "unsupported language",
+			file:    "run.sh",
+			content: "DB_PASSWORD='Hunter2!'\n",
+			want:    map[string][]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := patternMatcher.MatchSourceLiterals(tt.file, tt.content); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchSourceLiterals() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
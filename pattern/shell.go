@@ -0,0 +1,290 @@
+package pattern
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ShellCredentialRule is the name shell findings are reported under
+const ShellCredentialRule = "Shell Credential"
+
+// ShellSupersededRules are line rules replaced by the shell detector when scanning shell scripts
+var ShellSupersededRules = []string{"Password Usage", "GenericPass"}
+
+// ShellCommand is a single command of a script split into arguments
+type ShellCommand struct {
+	Line int
+	Args []string
+}
+
+// shellFinding is a credential passed on a command line, Index is the argument position within the command
+type shellFinding struct {
+	Command string
+	Index   int
+	Value   string
+	Line    int
+}
+
+var (
+	shebangPattern       = regexp.MustCompile(`^#!\s*\S*/(env\s+)?(ba|z|k|da)?sh\b`)
+	envAssignmentPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+)
+
+// IsShellScript reports whether a file is a shell script by extension or shebang
+func IsShellScript(name string, content string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".sh", ".bash", ".zsh", ".ksh":
+		return true
+	}
+	return shebangPattern.MatchString(strings.TrimLeft(content, " \t\r\n"))
+}
+
+// TokenizeShell splits a script into commands, honouring quotes, escapes, line continuations, comments and ; && || | &
+func TokenizeShell(script string) []ShellCommand {
+	var commands []ShellCommand
+	var args []string
+	var word strings.Builder
+	inWord := false
+	line, commandLine := 1, 1
+
+	endWord := func() {
+		if inWord {
+			args = append(args, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(args) > 0 {
+			commands = append(commands, ShellCommand{Line: commandLine, Args: args})
+		}
+		args = nil
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		if len(args) == 0 && !inWord {
+			commandLine = line
+		}
+		switch {
+		case r == '\\':
+			if i+1 < len(runes) {
+				i++
+				if runes[i] == '\n' {
+					line++
+					continue
+				}
+				word.WriteRune(runes[i])
+				inWord = true
+			}
+		case r == '\'':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '\''; i++ {
+				if runes[i] == '\n' {
+					line++
+				}
+				word.WriteRune(runes[i])
+			}
+		case r == '"':
+			inWord = true
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && strings.ContainsRune("\"\\$`", runes[i+1]) {
+					i++
+				} else if runes[i] == '\n' {
+					line++
+				}
+				word.WriteRune(runes[i])
+			}
+		case r == '#' && !inWord:
+			for i+1 < len(runes) && runes[i+1] != '\n' {
+				i++
+			}
+		case r == '\n':
+			endCommand()
+			line++
+		case r == ';' || r == '|' || r == '&':
+			endCommand()
+		case r == ' ' || r == '\t' || r == '\r':
+			endWord()
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	endCommand()
+	return commands
+}
+
+// flagValue returns the value of a flag given as "-p value", "-pvalue", "--flag value" or "--flag=value"
+func flagValue(args []string, i int, short string, long []string) (string, int, bool) {
+	arg := args[i]
+	for _, name := range long {
+		if arg == name && i+1 < len(args) {
+			return args[i+1], i + 1, true
+		}
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"="), i, true
+		}
+	}
+	if short != "" {
+		if arg == short && i+1 < len(args) {
+			return args[i+1], i + 1, true
+		}
+		if strings.HasPrefix(arg, short) && !strings.HasPrefix(arg, "--") && len(arg) > len(short) {
+			return strings.TrimPrefix(arg, short), i, true
+		}
+	}
+	return "", 0, false
+}
+
+// detectShellCommand applies the per-command credential rules
+func detectShellCommand(cmd ShellCommand) []shellFinding {
+	var findings []shellFinding
+	add := func(command string, index int, value string) {
+		if value != "" {
+			findings = append(findings, shellFinding{Command: command, Index: index, Value: value, Line: cmd.Line})
+		}
+	}
+
+	args := cmd.Args
+	start := 0
+	for start < len(args) {
+		// Leading NAME=value assignments, sudo and env wrappers
+		if m := envAssignmentPattern.FindStringSubmatch(args[start]); m != nil {
+			if IsSensitiveKey(m[1]) {
+				add(m[1]+"=", start, m[2])
+			}
+			start++
+			continue
+		}
+		if args[start] == "sudo" || args[start] == "env" || args[start] == "exec" {
+			start++
+			continue
+		}
+		break
+	}
+	if start >= len(args) {
+		return findings
+	}
+
+	program := path.Base(args[start])
+	switch program {
+	case "export", "declare", "typeset", "local", "readonly":
+		for i := start + 1; i < len(args); i++ {
+			if m := envAssignmentPattern.FindStringSubmatch(args[i]); m != nil && IsSensitiveKey(m[1]) {
+				add(program+" "+m[1], i-start, m[2])
+			}
+		}
+		return findings
+	case "aws":
+		// aws configure set aws_secret_access_key <value>
+		if len(args) > start+4 && args[start+1] == "configure" && args[start+2] == "set" && IsSensitiveKey(args[start+3]) {
+			add("aws configure set "+args[start+3], 4, args[start+4])
+		}
+	}
+
+	for i := start + 1; i < len(args); i++ {
+		arg := args[i]
+		var value string
+		var index int
+		var ok bool
+
+		switch program {
+		case "curl":
+			if value, index, ok = flagValue(args, i, "-u", []string{"--user", "--proxy-user"}); ok {
+				if strings.Contains(value, ":") && !strings.HasSuffix(value, ":") {
+					add("curl "+arg, index-start, value)
+				}
+				i = index
+				continue
+			}
+			if value, index, ok = flagValue(args, i, "-H", []string{"--header"}); ok {
+				if lower := strings.ToLower(value); strings.HasPrefix(lower, "authorization:") || strings.HasPrefix(lower, "x-api-key:") {
+					add("curl "+arg, index-start, value)
+				}
+				i = index
+				continue
+			}
+		case "mysql", "mysqldump", "mysqladmin", "mariadb", "mysqlimport":
+			// A bare -p prompts for the password, only -pSecret carries it
+			if strings.HasPrefix(arg, "-p") && len(arg) > 2 && !strings.HasPrefix(arg, "--") {
+				add(program+" -p", i-start, arg[2:])
+				continue
+			}
+		case "sshpass":
+			if value, index, ok = flagValue(args, i, "-p", nil); ok {
+				add("sshpass -p", index-start, value)
+				i = index
+				continue
+			}
+		case "docker", "podman", "helm", "nerdctl", "buildah", "skopeo":
+			if value, index, ok = flagValue(args, i, "-p", []string{"--password"}); ok && containsArg(args[start:], "login") {
+				add(program+" login "+strings.SplitN(arg, "=", 2)[0], index-start, value)
+				i = index
+				continue
+			}
+		case "wget":
+			if value, index, ok = flagValue(args, i, "", []string{"--password", "--http-password", "--ftp-password", "--proxy-password"}); ok {
+				add("wget "+strings.SplitN(arg, "=", 2)[0], index-start, value)
+				i = index
+				continue
+			}
+		}
+
+		// Generic --password=..., --token ..., --client-secret ... flags on any command
+		if strings.HasPrefix(arg, "--") {
+			name := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)[0]
+			if IsSensitiveKey(name) {
+				if value, index, ok = flagValue(args, i, "", []string{"--" + name}); ok && !strings.HasPrefix(value, "-") {
+					add(program+" --"+name, index-start, value)
+					i = index
+					continue
+				}
+			}
+		}
+
+		if connectionStringPattern.MatchString(arg) {
+			add(program, i-start, arg)
+		}
+	}
+	return findings
+}
+
+func containsArg(args []string, want string) bool {
+	for _, arg := range args {
+		if arg == want {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchShell tokenises a script and reports credentials passed on command lines
+// under "Shell Credential (<command> arg N, line L)"
+func (p *Patterns) MatchShell(script string) map[string][]string {
+	matches := make(map[string][]string)
+	for _, cmd := range TokenizeShell(script) {
+		for _, finding := range detectShellCommand(cmd) {
+			reportedName, keep := p.Filter.apply(ShellCredentialRule, nil, finding.Value)
+			if !keep {
+				continue
+			}
+			label := fmt.Sprintf("%s (%s arg %d, line %d)%s", ShellCredentialRule, finding.Command, finding.Index, finding.Line, strings.TrimPrefix(reportedName, ShellCredentialRule))
+			matches[label] = append(matches[label], finding.Value)
+		}
+	}
+	return matches
+}
+
+// MatchShellScript scans a shell script with the shell detector and the pattern set, without the line rules it supersedes
+func (p *Patterns) MatchShellScript(script string, matchMode string) map[string][]string {
+	matches := p.MatchPatternsExcept(script, matchMode, ShellSupersededRules)
+	for name, matchedStrings := range p.MatchShell(script) {
+		matches[name] = append(matches[name], matchedStrings...)
+	}
+	return matches
+}
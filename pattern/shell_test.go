@@ -0,0 +1,165 @@
+package pattern
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsShellScript(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    bool
+	}{
+		{"extension", "setup.sh", "", true},
+		{"bash shebang", "setup", "#!/bin/bash\necho", true},
+		{"env shebang", "setup", "\n#!/usr/bin/env sh\necho", true},
+		{"python shebang", "setup", "#!/usr/bin/env python3\nprint()", false},
+		{"no shebang", "setup.txt", "echo", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsShellScript(tt.file, tt.content); got != tt.want {
+				t.Errorf("IsShellScript() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeShell(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []ShellCommand
+	}{
+		{
+			name:   "quotes and escapes",
+			script: `echo 'single quoted' "double \"quoted\"" escaped\ space`,
+			want:   []ShellCommand{{Line: 1, Args: []string{"echo", "single quoted", `double "quoted"`, "escaped space"}}},
+		},
+		{
+			name:   "separators",
+			script: "a 1; b 2 && c 3 | d",
+			want: []ShellCommand{
+				{Line: 1, Args: []string{"a", "1"}},
+				{Line: 1, Args: []string{"b", "2"}},
+				{Line: 1, Args: []string{"c", "3"}},
+				{Line: 1, Args: []string{"d"}},
+			},
+		},
+		{
+			name:   "comments and line continuations",
+			script: "# setup\ncurl \\\n  -u user:pass https://x # trailing\necho done",
+			want: []ShellCommand{
+				{Line: 2, Args: []string{"curl", "-u", "user:pass", "https://x"}},
+				{Line: 4, Args: []string{"echo", "done"}},
+			},
+		},
+		{
+			name:   "hash inside a word",
+			script: "echo a#b",
+			want:   []ShellCommand{{Line: 1, Args: []string{"echo", "a#b"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TokenizeShell(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TokenizeShell() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchShell(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   map[string][]string
+	}{
+		{
+			name:   "curl basic auth",
+			script: "curl -u admin:Hunter2 https://example.com",
+			want:   map[string][]string{"Shell Credential (curl -u arg 2, line 1)": {"admin:Hunter2"}},
+		},
+		{
+			name:   "curl user without password",
+			script: "curl -u admin: https://example.com",
+			want:   map[string][]string{},
+		},
+		{
+			name:   "curl authorization header",
+			script: `curl -H "Authorization: Bearer abc" https://example.com`,
+			want:   map[string][]string{"Shell Credential (curl -H arg 2, line 1)": {"Authorization: Bearer abc"}},
+		},
+		{
+			name:   "mysql password",
+			script: "mysql -u root -pHunter2 db\nmysql -u root -p db",
+			want:   map[string][]string{"Shell Credential (mysql -p arg 3, line 1)": {"Hunter2"}},
+		},
+		{
+			name:   "docker login",
+			script: "docker login -u user --password=Hunter2 registry",
+			want:   map[string][]string{"Shell Credential (docker login --password arg 4, line 1)": {"Hunter2"}},
+		},
+		{
+			name:   "sshpass",
+			script: "sshpass -p Hunter2 ssh host",
+			want:   map[string][]string{"Shell Credential (sshpass -p arg 2, line 1)": {"Hunter2"}},
+		},
+		{
+			name:   "export and prefix assignment",
+			script: "export AWS_SECRET_ACCESS_KEY=abc STAGE=prod\nDB_PASSWORD=Hunter2 ./run.sh",
+			want: map[string][]string{
+				"Shell Credential (export AWS_SECRET_ACCESS_KEY arg 1, line 1)": {"abc"},
+				"Shell Credential (DB_PASSWORD= arg 0, line 2)":                 {"Hunter2"},
+			},
+		},
+		{
+			name:   "aws configure set",
+			script: "aws configure set aws_secret_access_key abc",
+			want:   map[string][]string{"Shell Credential (aws configure set aws_secret_access_key arg 4, line 1)": {"abc"}},
+		},
+		{
+			name:   "generic flag and connection string",
+			script: "tool --client-secret abc --verbose\npsql postgres://admin:Hunter2@db/app",
+			want: map[string][]string{
+				"Shell Credential (tool --client-secret arg 2, line 1)": {"abc"},
+				"Shell Credential (psql arg 1, line 2)":                 {"postgres://admin:Hunter2@db/app"},
+			},
+		},
+		{
+			name:   "reference is labelled by the filter",
+			script: "export DB_PASSWORD=$SECRET",
+			want:   map[string][]string{"Shell Credential (export DB_PASSWORD arg 1, line 1) [informational: reference]": {"$SECRET"}},
+		},
+	}
+	filter, err := NewFilter(FilterInfo, "")
+	if err != nil {
+		t.Fatalf("NewFilter() error = %v", err)
+	}
+	p := &Patterns{Filter: filter}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := p.MatchShell(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("MatchShell() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchShellScriptSkipsSupersededRules(t *testing.T) {
+	patternMatcher, err := LoadPatterns("content.json")
+	if err != nil {
+		t.Fatalf("LoadPatterns() error = %v", err)
+	}
+	matches := patternMatcher.MatchShellScript("export DB_PASSWORD=Hunter2", "MatchString")
+	for _, rule := range ShellSupersededRules {
+		if _, ok := matches[rule]; ok {
+			t.Errorf("MatchShellScript() reported superseded rule %s", rule)
+		}
+	}
+	if _, ok := matches["Shell Credential (export DB_PASSWORD arg 1, line 1)"]; !ok {
+		t.Errorf("MatchShellScript() = %v, want the shell credential", matches)
+	}
+}
@@ -0,0 +1,110 @@
+package pattern
+
+import (
+	"hash/crc32"
+	"regexp"
+	"strings"
+)
+
+// Validator checks the offline structure of a token, it receives the full match and returns true if any token in it is well formed
+type Validator func(match string) bool
+
+// Validation modes a rule can use in the pattern file
+const (
+	ValidationRequire = "require"
+	ValidationMark    = "mark"
+)
+
+// Validators is the registry of validators that rules in the pattern file can reference by name
+var Validators = map[string]Validator{
+	"github_token":      validateGitHubToken,
+	"slack_token":       validateSlackToken,
+	"aws_access_key_id": validateAWSAccessKeyID,
+}
+
+type ruleValidator struct {
+	Name     string
+	Validate Validator
+	Require  bool
+}
+
+const base62Alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+var (
+	gitHubTokenPattern = regexp.MustCompile(`gh[pousr]_[A-Za-z0-9]{36}`)
+	slackTokenPattern  = regexp.MustCompile(`xox[abposr]-[A-Za-z0-9\-]+`)
+	awsKeyIDPattern    = regexp.MustCompile(`(?:A3T[A-Z0-9]|AKIA|AGPA|AIDA|ANPA|ANVA|AROA|ASIA)[A-Z0-9]{16}`)
+	digitsPattern      = regexp.MustCompile(`^[0-9]+$`)
+	alnumPattern       = regexp.MustCompile(`^[A-Za-z0-9]+$`)
+	hexPattern         = regexp.MustCompile(`^[a-f0-9]{32}$`)
+	base32Pattern      = regexp.MustCompile(`^[A-Z2-7]{16}$`)
+)
+
+// validateGitHubToken checks the CRC32 checksum GitHub appends (base62, 6 chars) to the 30 random characters of ghp_/ghs_ tokens
+func validateGitHubToken(match string) bool {
+	for _, token := range gitHubTokenPattern.FindAllString(match, -1) {
+		body := token[4:]
+		payload, checksum := body[:30], body[30:]
+		if encodeBase62(uint64(crc32.ChecksumIEEE([]byte(payload))), 6) == checksum {
+			return true
+		}
+	}
+	return false
+}
+
+func encodeBase62(value uint64, width int) string {
+	var out []byte
+	for value > 0 {
+		out = append([]byte{base62Alphabet[value%62]}, out...)
+		value /= 62
+	}
+	for len(out) < width {
+		out = append([]byte{'0'}, out...)
+	}
+	return string(out)
+}
+
+// validateSlackToken checks the segment layout of Slack bot (xoxb), user (xoxp) and app/refresh tokens
+func validateSlackToken(match string) bool {
+	for _, token := range slackTokenPattern.FindAllString(match, -1) {
+		segments := strings.Split(token, "-")
+		switch segments[0] {
+		case "xoxb":
+			// xoxb-<team>-<bot>-<24 alnum>
+			if len(segments) == 4 && digitsPattern.MatchString(segments[1]) && digitsPattern.MatchString(segments[2]) &&
+				len(segments[3]) == 24 && alnumPattern.MatchString(segments[3]) {
+				return true
+			}
+		case "xoxp":
+			// xoxp-<team>-<user>-<team-user>-<32 hex>
+			if len(segments) == 5 && digitsPattern.MatchString(segments[1]) && digitsPattern.MatchString(segments[2]) &&
+				digitsPattern.MatchString(segments[3]) && hexPattern.MatchString(segments[4]) {
+				return true
+			}
+		default:
+			// xoxa-2-, xoxr-, xoxs-, xoxo- carry at least a numeric segment and a random one
+			if len(segments) >= 3 && digitsPattern.MatchString(segments[1]) && alnumPattern.MatchString(segments[len(segments)-1]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// validateAWSAccessKeyID checks that the 16 characters after the prefix are base32, as every key ID AWS issues is
+func validateAWSAccessKeyID(match string) bool {
+	for _, keyID := range awsKeyIDPattern.FindAllString(match, -1) {
+		if base32Pattern.MatchString(keyID[4:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// check runs the validator and reports whether the match should be kept and whether its format was verified
+func (v ruleValidator) check(match string) (keep bool, verified bool) {
+	if v.Validate(match) {
+		return true, true
+	}
+	return !v.Require, false
+}
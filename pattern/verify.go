@@ -0,0 +1,283 @@
+package pattern
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+)
+
+// Labels added to verified matches, CriticalLabel sorts first in the output
+const (
+	CriticalLabel = "CRITICAL: verified active"
+	InactiveLabel = "verified inactive"
+
+	verifyTimeout = 10 * time.Second
+)
+
+// Verifier checks whether the credential in a match is live, input is the whole content the match was found in
+type Verifier interface {
+	Verify(ctx context.Context, re *regexp.Regexp, match string, input string) (bool, error)
+}
+
+// CacheKeyer is implemented by verifiers whose result depends on more than the match, such as the secret paired
+// with an AWS key ID, the key replaces the match in the verification cache
+type CacheKeyer interface {
+	CacheKey(match string, input string) string
+}
+
+// HTTPVerifier is a generic verifier described in the pattern file
+type HTTPVerifier struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Header string `json:"header"`
+	Value  string `json:"value"`
+	Status int    `json:"status"`
+	// Inactive lists the statuses meaning the token was rejected, 401 and 403 when empty
+	Inactive []int `json:"inactive"`
+
+	Client *http.Client `json:"-"`
+}
+
+// Verify sends the request with the token substituted into the header value template ({{token}})
+func (v *HTTPVerifier) Verify(ctx context.Context, re *regexp.Regexp, match string, input string) (bool, error) {
+	method := v.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	header := v.Header
+	if header == "" {
+		header = "Authorization"
+	}
+	status := v.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: verifyTimeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, v.URL, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set(header, strings.ReplaceAll(v.Value, "{{token}}", extractToken(re, match)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == status {
+		return true, nil
+	}
+	inactive := v.Inactive
+	if len(inactive) == 0 {
+		inactive = []int{http.StatusUnauthorized, http.StatusForbidden}
+	}
+	for _, code := range inactive {
+		if resp.StatusCode == code {
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("unexpected status %d from %s", resp.StatusCode, v.URL)
+}
+
+// extractToken returns the first capture group of the rule, which holds the token for most patterns
+func extractToken(re *regexp.Regexp, match string) string {
+	if re != nil {
+		if groups := re.FindStringSubmatch(match); len(groups) > 1 && groups[1] != "" {
+			return groups[1]
+		}
+	}
+	return strings.TrimSpace(match)
+}
+
+// AWSVerifier pairs an access key ID with the secret keys found next to it and calls STS GetCallerIdentity
+type AWSVerifier struct {
+	Endpoint string
+	Region   string
+	// Secret is the AWS_Secret rule, its secret group pairs with key IDs anywhere in the content
+	Secret *regexp.Regexp
+}
+
+var awsSecretPattern = regexp.MustCompile(`(?:^|[^A-Za-z0-9/+])([A-Za-z0-9/+]{40})(?:[^A-Za-z0-9/+]|$)`)
+
+const (
+	// maxSecretCandidates caps the STS calls made for a single key ID
+	maxSecretCandidates = 5
+	// secretCandidateLines is how many lines around the key ID are searched for an unlabelled secret
+	secretCandidateLines = 3
+)
+
+// NewAWSVerifier creates an STS based verifier, endpoint overrides the STS URL when not empty
+func NewAWSVerifier(endpoint string, secret *regexp.Regexp) *AWSVerifier {
+	return &AWSVerifier{Endpoint: endpoint, Region: "us-east-1", Secret: secret}
+}
+
+func (v *AWSVerifier) Verify(ctx context.Context, re *regexp.Regexp, match string, input string) (bool, error) {
+	secrets := v.secretCandidates(match, input)
+	if len(secrets) == 0 {
+		return false, errors.New("no secret access key found next to the key ID")
+	}
+
+	var endpoint *string
+	if v.Endpoint != "" {
+		endpoint = aws.String(v.Endpoint)
+	}
+
+	var lastErr error
+	for _, keyID := range awsKeyIDPattern.FindAllString(match, -1) {
+		for _, secret := range secrets {
+			client := sts.New(sts.Options{
+				Region:       v.Region,
+				Credentials:  credentials.NewStaticCredentialsProvider(keyID, secret, ""),
+				BaseEndpoint: endpoint,
+			})
+			_, err := client.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+			if err == nil {
+				return true, nil
+			}
+			var ae smithy.APIError
+			if !errors.As(err, &ae) {
+				lastErr = err
+			}
+		}
+	}
+	return false, lastErr
+}
+
+// CacheKey keys a result by the key ID and the secrets it was paired with, so the same key ID found next to a
+// different secret is verified again
+func (v *AWSVerifier) CacheKey(match string, input string) string {
+	return match + "\x00" + strings.Join(v.secretCandidates(match, input), "\x00")
+}
+
+// secretCandidates returns the values of the AWS_Secret rule, then the 40 character strings a few lines around the match
+func (v *AWSVerifier) secretCandidates(match string, input string) []string {
+	var secrets []string
+	seen := make(map[string]bool)
+	add := func(secret string) {
+		if secret != "" && !seen[secret] && len(secrets) < maxSecretCandidates {
+			seen[secret] = true
+			secrets = append(secrets, secret)
+		}
+	}
+
+	if v.Secret != nil {
+		if index := v.Secret.SubexpIndex("secret"); index > 0 {
+			for _, groups := range v.Secret.FindAllStringSubmatch(input, -1) {
+				add(groups[index])
+			}
+		}
+	}
+
+	offset := strings.Index(input, match)
+	if offset < 0 {
+		return secrets
+	}
+	lines := strings.Split(input, "\n")
+	first := strings.Count(input[:offset], "\n")
+	last := first + strings.Count(match, "\n")
+	from, to := max(first-secretCandidateLines, 0), min(last+secretCandidateLines+1, len(lines))
+	for _, groups := range awsSecretPattern.FindAllStringSubmatch(strings.Join(lines[from:to], "\n"), -1) {
+		add(groups[1])
+	}
+	return secrets
+}
+
+// AddVerifier registers a verifier for the given pattern name
+func (p *Patterns) AddVerifier(name string, verifier Verifier) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.Verifiers == nil {
+		p.Verifiers = make(map[string]Verifier)
+	}
+	p.Verifiers[name] = verifier
+}
+
+// EnableVerification turns on live verification, endpoints maps a pattern name to a URL that replaces its verifier endpoint
+func (p *Patterns) EnableVerification(endpoints map[string]string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.verify = true
+	p.verified = make(map[string]string)
+	for name, verifier := range p.Verifiers {
+		endpoint, ok := endpoints[name]
+		if !ok {
+			continue
+		}
+		switch v := verifier.(type) {
+		case *HTTPVerifier:
+			v.URL = endpoint
+		case *AWSVerifier:
+			v.Endpoint = endpoint
+		}
+	}
+}
+
+// verifyMatch returns the verification label for a match, results are cached per pattern and match while failed
+// verifications are retried the next time the match is found
+func (p *Patterns) verifyMatch(name string, re *regexp.Regexp, match string, input string) string {
+	p.mu.Lock()
+	verifier, ok := p.Verifiers[name]
+	enabled := p.verify
+	p.mu.Unlock()
+	if !enabled || !ok {
+		return ""
+	}
+	cacheKey := name + "\x00" + match
+	if keyer, ok := verifier.(CacheKeyer); ok {
+		cacheKey = name + "\x00" + keyer.CacheKey(match, input)
+	}
+	p.mu.Lock()
+	label, cached := p.verified[cacheKey]
+	p.mu.Unlock()
+	if cached {
+		return label
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifyTimeout)
+	defer cancel()
+	active, err := verifier.Verify(ctx, re, match, input)
+	if err != nil {
+		log.Printf("Failed to verify %s match: %v", name, err)
+		return ""
+	}
+	label = InactiveLabel
+	if active {
+		label = CriticalLabel
+	}
+
+	p.mu.Lock()
+	p.verified[cacheKey] = label
+	p.mu.Unlock()
+	return label
+}
+
+// ParseEndpoints parses "name=url,name=url" overrides for the -verify-endpoint flag
+func ParseEndpoints(value string) (map[string]string, error) {
+	endpoints := make(map[string]string)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, url, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(url) == "" {
+			return nil, fmt.Errorf("invalid verify endpoint %q (expected name=url)", pair)
+		}
+		endpoints[strings.TrimSpace(name)] = strings.TrimSpace(url)
+	}
+	return endpoints, nil
+}
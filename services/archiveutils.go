@@ -0,0 +1,219 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ArchiveLimits protects extraction against zip bombs and deeply nested archives
+type ArchiveLimits struct {
+	MaxDepth     int
+	MaxEntries   int
+	MaxTotalSize int64
+}
+
+// DefaultArchiveLimits are used when scanning deployment packages and S3 artifacts
+var DefaultArchiveLimits = ArchiveLimits{
+	MaxDepth:     3,
+	MaxEntries:   20000,
+	MaxTotalSize: 512 << 20,
+}
+
+// ErrArchiveLimit is returned when an archive exceeds one of the ArchiveLimits
+var ErrArchiveLimit = errors.New("archive limits exceeded")
+
+// PartiallyScanned prefixes the status of packages and layers whose extraction stopped at the ArchiveLimits
+const PartiallyScanned = "partially scanned"
+
+const (
+	archiveZip   = "zip"
+	archiveTar   = "tar"
+	archiveTarGz = "tar.gz"
+)
+
+type archiveExtractor struct {
+	filter  FileFilter
+	limits  ArchiveLimits
+	entries int
+	total   int64
+	files   []SourceFile
+}
+
+// ExtractArchive walks a zip, jar, whl, egg, tar or tar.gz archive and the archives nested in it, returning the text files
+// that pass the filter. Nested paths are joined with "!/", e.g. app.jar!/BOOT-INF/classes/application.yml.
+// The files extracted before a limit was hit are returned along with ErrArchiveLimit.
+func ExtractArchive(name string, r io.ReaderAt, size int64, filter FileFilter, limits ArchiveLimits) ([]SourceFile, error) {
+	e := &archiveExtractor{filter: filter, limits: limits}
+
+	header := make([]byte, 512)
+	n, _ := r.ReadAt(header, 0)
+	var err error
+	switch archiveKind(name, header[:n]) {
+	case archiveZip:
+		err = e.extractZip("", r, size, 0)
+	case archiveTar:
+		err = e.extractTar("", io.NewSectionReader(r, 0, size), 0)
+	case archiveTarGz:
+		var gz *gzip.Reader
+		gz, err = gzip.NewReader(io.NewSectionReader(r, 0, size))
+		if err == nil {
+			err = e.extractTar("", gz, 0)
+			gz.Close()
+		}
+	default:
+		err = fmt.Errorf("%s is not a supported archive", name)
+	}
+	return e.files, err
+}
+
+// ExtractTar walks a tar stream, such as a decompressed container image layer, with the same rules as ExtractArchive
+func ExtractTar(r io.Reader, filter FileFilter, limits ArchiveLimits) ([]SourceFile, error) {
+	e := &archiveExtractor{filter: filter, limits: limits}
+	err := e.extractTar("", r, 0)
+	return e.files, err
+}
+
+// archiveKind detects the archive format from the name and the first bytes of the content
+func archiveKind(name string, header []byte) string {
+	lower := strings.ToLower(name)
+	switch {
+	case bytes.HasPrefix(header, []byte("PK\x03\x04")) || bytes.HasPrefix(header, []byte("PK\x05\x06")):
+		return archiveZip
+	case bytes.HasPrefix(header, []byte{0x1f, 0x8b}) &&
+		(strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") || name == ""):
+		return archiveTarGz
+	case len(header) >= 262 && string(header[257:262]) == "ustar":
+		return archiveTar
+	}
+	return ""
+}
+
+func (e *archiveExtractor) extractZip(prefix string, r io.ReaderAt, size int64, depth int) error {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to open zip %s: %w", strings.TrimSuffix(prefix, "!/"), err)
+	}
+	for _, file := range reader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			continue
+		}
+		err = e.addEntry(prefix, file.Name, rc, depth)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *archiveExtractor) extractTar(prefix string, r io.Reader, depth int) error {
+	reader := tar.NewReader(r)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read tar %s: %w", strings.TrimSuffix(prefix, "!/"), err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := e.addEntry(prefix, header.Name, reader, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// addEntry reads a single entry within the limits, recursing into nested archives. Nested archives only bypass the
+// include globs, so *.yml still finds app.jar!/application.yml, while an exclude glob such as **/node_modules/**
+// skips the archives below it as well
+func (e *archiveExtractor) addEntry(prefix string, name string, r io.Reader, depth int) error {
+	name = strings.TrimPrefix(name, "./")
+	fullPath := prefix + name
+	if e.filter.Excludes(fullPath) || !hasArchiveExtension(name) && !e.filter.Allows(fullPath) {
+		return nil
+	}
+
+	e.entries++
+	if e.limits.MaxEntries > 0 && e.entries > e.limits.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrArchiveLimit, e.limits.MaxEntries)
+	}
+
+	remaining := e.limits.MaxTotalSize - e.total
+	data, err := io.ReadAll(io.LimitReader(r, remaining+1))
+	if err != nil {
+		return nil
+	}
+	if int64(len(data)) > remaining {
+		return fmt.Errorf("%w: more than %d bytes decompressed", ErrArchiveLimit, e.limits.MaxTotalSize)
+	}
+	e.total += int64(len(data))
+
+	if kind := nestedArchiveKind(name, data); kind != "" {
+		if depth+1 > e.limits.MaxDepth {
+			return nil
+		}
+		nestedPrefix := fullPath + "!/"
+		switch kind {
+		case archiveZip:
+			err = e.extractZip(nestedPrefix, bytes.NewReader(data), int64(len(data)), depth+1)
+		case archiveTar:
+			err = e.extractTar(nestedPrefix, bytes.NewReader(data), depth+1)
+		case archiveTarGz:
+			var gz *gzip.Reader
+			if gz, err = gzip.NewReader(bytes.NewReader(data)); err == nil {
+				err = e.extractTar(nestedPrefix, gz, depth+1)
+				gz.Close()
+			}
+		}
+		if errors.Is(err, ErrArchiveLimit) {
+			return err
+		}
+		return nil
+	}
+
+	if isBinary(data) || !e.filter.Allows(fullPath) {
+		return nil
+	}
+	e.files = append(e.files, SourceFile{Path: fullPath, Content: string(data)})
+	return nil
+}
+
+// hasArchiveExtension reports whether the entry name is one nestedArchiveKind may descend into
+func hasArchiveExtension(name string) bool {
+	switch path.Ext(strings.ToLower(name)) {
+	case ".zip", ".jar", ".war", ".ear", ".whl", ".egg", ".tar", ".tgz", ".gz":
+		return true
+	}
+	return false
+}
+
+// nestedArchiveKind only descends into entries whose extension says they are archives
+func nestedArchiveKind(name string, data []byte) string {
+	lower := strings.ToLower(name)
+	switch path.Ext(lower) {
+	case ".zip", ".jar", ".war", ".ear", ".whl", ".egg":
+		if bytes.HasPrefix(data, []byte("PK")) {
+			return archiveZip
+		}
+	case ".tar":
+		return archiveTar
+	case ".tgz", ".gz":
+		if strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz") {
+			return archiveTarGz
+		}
+	}
+	return ""
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type archiveEntry struct {
+	name    string
+	content []byte
+}
+
+func zipArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, entry := range entries {
+		f, err := w.Create(entry.name)
+		if err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if _, err := f.Write(entry.content); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, entries ...archiveEntry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	w := tar.NewWriter(gz)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644, Size: int64(len(entry.content)), Typeflag: tar.TypeReg}
+		if err := w.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+		if _, err := w.Write(entry.content); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func sourcePaths(files []SourceFile) []string {
+	var paths []string
+	for _, file := range files {
+		paths = append(paths, file.Path)
+	}
+	return paths
+}
+
+func TestExtractArchive(t *testing.T) {
+	jar := zipArchive(t,
+		archiveEntry{"BOOT-INF/classes/application.yml", []byte("password: x")},
+		archiveEntry{"Main.class", []byte("\xca\xfe\xba\xbe\x00\x00")},
+	)
+	wheel := tarGzArchive(t, archiveEntry{"./pkg/settings.py", []byte("TOKEN = 'x'")})
+	deepest := zipArchive(t, archiveEntry{"deep.yml", []byte("a: b")})
+	nested := zipArchive(t, archiveEntry{"level3.zip", deepest})
+	nested = zipArchive(t, archiveEntry{"level2.zip", nested})
+	nested = zipArchive(t, archiveEntry{"level1.zip", nested})
+	tests := []struct {
+		name    string
+		archive []byte
+		filter  FileFilter
+		limits  ArchiveLimits
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:    "nested jar with a base name include",
+			archive: zipArchive(t, archiveEntry{"app.jar", jar}, archiveEntry{"index.py", []byte("x = 1")}),
+			filter:  FileFilter{Include: []string{"*.yml"}},
+			limits:  DefaultArchiveLimits,
+			want:    []string{"app.jar!/BOOT-INF/classes/application.yml"},
+		},
+		{
+			name:    "nested tarball and binary files skipped",
+			archive: zipArchive(t, archiveEntry{"lib/pkg.tar.gz", wheel}, archiveEntry{"app.jar", jar}),
+			limits:  DefaultArchiveLimits,
+			want:    []string{"lib/pkg.tar.gz!/pkg/settings.py", "app.jar!/BOOT-INF/classes/application.yml"},
+		},
+		{
+			name:    "exclude glob",
+			archive: zipArchive(t, archiveEntry{"index.py", []byte("x = 1")}, archiveEntry{"test.pyc", []byte("x")}),
+			filter:  FileFilter{Exclude: []string{"*.pyc"}},
+			limits:  DefaultArchiveLimits,
+			want:    []string{"index.py"},
+		},
+		{
+			name:    "exclude glob skips nested archives",
+			archive: zipArchive(t, archiveEntry{"node_modules/dep/app.jar", jar}, archiveEntry{"app.jar", jar}),
+			filter:  FileFilter{Include: []string{"*.yml"}, Exclude: []string{"**/node_modules/**"}},
+			limits:  DefaultArchiveLimits,
+			want:    []string{"app.jar!/BOOT-INF/classes/application.yml"},
+		},
+		{
+			name:    "max depth stops descending",
+			archive: nested,
+			limits:  ArchiveLimits{MaxDepth: 2, MaxEntries: 100, MaxTotalSize: 1 << 20},
+			want:    nil,
+		},
+		{
+			name:    "max entries",
+			archive: zipArchive(t, archiveEntry{"a.txt", []byte("a")}, archiveEntry{"b.txt", []byte("b")}, archiveEntry{"c.txt", []byte("c")}),
+			limits:  ArchiveLimits{MaxDepth: 3, MaxEntries: 2, MaxTotalSize: 1 << 20},
+			want:    []string{"a.txt", "b.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "max total size",
+			archive: zipArchive(t, archiveEntry{"a.txt", []byte("aaaa")}, archiveEntry{"b.txt", []byte("bbbb")}),
+			limits:  ArchiveLimits{MaxDepth: 3, MaxEntries: 100, MaxTotalSize: 6},
+			want:    []string{"a.txt"},
+			wantErr: true,
+		},
+		{
+			name:    "limit inside a nested archive",
+			archive: zipArchive(t, archiveEntry{"app.jar", zipArchive(t, archiveEntry{"a.txt", []byte("a")}, archiveEntry{"b.txt", []byte("b")})}),
+			limits:  ArchiveLimits{MaxDepth: 3, MaxEntries: 2, MaxTotalSize: 1 << 20},
+			want:    []string{"app.jar!/a.txt"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := ExtractArchive("package.zip", bytes.NewReader(tt.archive), int64(len(tt.archive)), tt.filter, tt.limits)
+			if tt.wantErr != errors.Is(err, ErrArchiveLimit) {
+				t.Fatalf("ExtractArchive() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("ExtractArchive() error = %v", err)
+			}
+			if got := sourcePaths(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ExtractArchive() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractArchiveUnsupported(t *testing.T) {
+	data := []byte("just text")
+	if _, err := ExtractArchive("notes.txt", bytes.NewReader(data), int64(len(data)), FileFilter{}, DefaultArchiveLimits); err == nil {
+		t.Errorf("ExtractArchive() error = nil, want an unsupported archive error")
+	}
+}
+
+func TestArchiveKind(t *testing.T) {
+	ustar := make([]byte, 512)
+	copy(ustar[257:], "ustar")
+	tests := []struct {
+		name   string
+		file   string
+		header []byte
+		want   string
+	}{
+		{"zip", "package", []byte("PK\x03\x04"), archiveZip},
+		{"empty zip", "package.zip", []byte("PK\x05\x06"), archiveZip},
+		{"tar.gz", "layer.tar.gz", []byte{0x1f, 0x8b}, archiveTarGz},
+		{"tgz", "layer.tgz", []byte{0x1f, 0x8b}, archiveTarGz},
+		{"unnamed gzip", "", []byte{0x1f, 0x8b}, archiveTarGz},
+		{"plain gzip", "script.py.gz", []byte{0x1f, 0x8b}, ""},
+		{"tar", "layer", ustar, archiveTar},
+		{"text", "notes.txt", []byte("hello"), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := archiveKind(tt.file, tt.header); got != tt.want {
+				t.Errorf("archiveKind(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNestedArchiveKind(t *testing.T) {
+	tests := []struct {
+		file          string
+		data          []byte
+		want          string
+		wantExtension bool
+	}{
+		{"app.JAR", []byte("PK\x03\x04"), archiveZip, true},
+		{"pkg.whl", []byte("PK\x03\x04"), archiveZip, true},
+		{"fake.zip", []byte("not a zip"), "", true},
+		{"layer.tar", nil, archiveTar, true},
+		{"pkg.tar.gz", nil, archiveTarGz, true},
+		{"pkg.tgz", nil, archiveTarGz, true},
+		{"data.json.gz", nil, "", true},
+		{"application.yml", []byte("PK"), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			if got := nestedArchiveKind(tt.file, tt.data); got != tt.want {
+				t.Errorf("nestedArchiveKind(%q) = %q, want %q", tt.file, got, tt.want)
+			}
+			if got := hasArchiveExtension(tt.file); got != tt.wantExtension {
+				t.Errorf("hasArchiveExtension(%q) = %v, want %v", tt.file, got, tt.wantExtension)
+			}
+		})
+	}
+}
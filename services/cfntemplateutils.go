@@ -0,0 +1,262 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"awsecrets/pattern"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	cfTypes "github.com/aws/aws-sdk-go-v2/service/cloudformation/types"
+	"gopkg.in/yaml.v3"
+)
+
+// TemplateFinding is a rule violation found while walking a parsed CloudFormation template
+type TemplateFinding struct {
+	Rule  string
+	Path  string
+	Value string
+}
+
+var (
+	dynamicSecretPattern = regexp.MustCompile(`\{\{resolve:(secretsmanager|ssm-secure):[^}]*\}\}`)
+
+	// Resource properties that are expected to receive a credential and should never carry a literal value
+	sensitiveProperties = map[string]bool{
+		"MasterUserPassword": true,
+		"MasterPassword":     true,
+		"Password":           true,
+		"AdminPassword":      true,
+		"AuthToken":          true,
+		"SecretString":       true,
+		"ConnectionString":   true,
+		"ConnectionUri":      true,
+		"DBConnectionString": true,
+		"SecretAccessKey":    true,
+		"ClientSecret":       true,
+		"PrivateKey":         true,
+	}
+)
+
+// parseTemplate decodes a JSON or YAML template, turning short-form intrinsics (!Sub, !Ref, ...) into their long form
+func parseTemplate(body string) (map[string]interface{}, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(body), &root); err != nil {
+		var doc map[string]interface{}
+		if jsonErr := json.Unmarshal([]byte(body), &doc); jsonErr != nil {
+			return nil, err
+		}
+		return doc, nil
+	}
+	doc, ok := templateNodeValue(&root).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("template is not a mapping")
+	}
+	return doc, nil
+}
+
+func templateNodeValue(node *yaml.Node) interface{} {
+	var value interface{}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return templateNodeValue(node.Content[0])
+	case yaml.AliasNode:
+		return templateNodeValue(node.Alias)
+	case yaml.MappingNode:
+		m := make(map[string]interface{}, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			m[node.Content[i].Value] = templateNodeValue(node.Content[i+1])
+		}
+		value = m
+	case yaml.SequenceNode:
+		list := make([]interface{}, 0, len(node.Content))
+		for _, item := range node.Content {
+			list = append(list, templateNodeValue(item))
+		}
+		value = list
+	default:
+		if err := node.Decode(&value); err != nil || node.Style != 0 || strings.HasPrefix(node.Tag, "!") {
+			value = node.Value
+		}
+	}
+
+	if !strings.HasPrefix(node.Tag, "!") || strings.HasPrefix(node.Tag, "!!") {
+		return value
+	}
+	intrinsic := strings.TrimPrefix(node.Tag, "!")
+	if intrinsic == "Ref" || intrinsic == "Condition" {
+		return map[string]interface{}{intrinsic: value}
+	}
+	if intrinsic == "GetAtt" {
+		if s, ok := value.(string); ok {
+			parts := strings.SplitN(s, ".", 2)
+			list := make([]interface{}, 0, len(parts))
+			for _, part := range parts {
+				list = append(list, part)
+			}
+			value = list
+		}
+	}
+	return map[string]interface{}{"Fn::" + intrinsic: value}
+}
+
+// AnalyzeTemplate applies the semantic CloudFormation rules to a template body
+func AnalyzeTemplate(body string, patternMatcher *pattern.Patterns, matchMode string) ([]TemplateFinding, error) {
+	doc, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []TemplateFinding
+
+	parameters, _ := doc["Parameters"].(map[string]interface{})
+	for _, name := range sortedKeys(parameters) {
+		param, ok := parameters[name].(map[string]interface{})
+		if !ok || !pattern.IsSensitiveKey(name) {
+			continue
+		}
+		if !isTrue(param["NoEcho"]) {
+			findings = append(findings, TemplateFinding{
+				Rule: "Credential Parameter Without NoEcho",
+				Path: "Parameters." + name,
+			})
+		}
+		if def, ok := literalString(param["Default"]); ok && def != "" {
+			findings = appendLiteralFinding(findings, patternMatcher, "Credential Parameter With Default Value", "Parameters."+name+".Default", def)
+		}
+	}
+
+	resources, _ := doc["Resources"].(map[string]interface{})
+	for _, name := range sortedKeys(resources) {
+		resource, ok := resources[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		properties, ok := resource["Properties"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		findings = append(findings, analyzeProperties("Resources."+name+".Properties", properties, patternMatcher, matchMode)...)
+	}
+
+	return findings, nil
+}
+
+func analyzeProperties(path string, value interface{}, patternMatcher *pattern.Patterns, matchMode string) []TemplateFinding {
+	var findings []TemplateFinding
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			childPath := path + "." + key
+			if key == "Variables" && strings.HasSuffix(path, ".Environment") {
+				findings = append(findings, analyzeEnvironment(childPath, v[key], patternMatcher, matchMode)...)
+				continue
+			}
+			if sensitiveProperties[key] {
+				if literal, ok := literalString(v[key]); ok && literal != "" {
+					findings = appendLiteralFinding(findings, patternMatcher, "Hardcoded Sensitive Property", childPath, literal)
+				}
+				continue
+			}
+			findings = append(findings, analyzeProperties(childPath, v[key], patternMatcher, matchMode)...)
+		}
+	case []interface{}:
+		for i, item := range v {
+			findings = append(findings, analyzeProperties(fmt.Sprintf("%s[%d]", path, i), item, patternMatcher, matchMode)...)
+		}
+	}
+	return findings
+}
+
+func analyzeEnvironment(path string, value interface{}, patternMatcher *pattern.Patterns, matchMode string) []TemplateFinding {
+	var findings []TemplateFinding
+	variables, _ := value.(map[string]interface{})
+	for _, name := range sortedKeys(variables) {
+		literal, ok := literalString(variables[name])
+		if !ok || literal == "" {
+			continue
+		}
+		if pattern.IsSensitiveKey(name) {
+			findings = appendLiteralFinding(findings, patternMatcher, "Hardcoded Environment Variable", path+"."+name, literal)
+			continue
+		}
+		for patternName := range patternMatcher.MatchPatterns(literal, matchMode) {
+			findings = append(findings, TemplateFinding{
+				Rule:  "Hardcoded Environment Variable (Pattern: " + patternName + ")",
+				Path:  path + "." + name,
+				Value: literal,
+			})
+		}
+	}
+	return findings
+}
+
+// appendLiteralFinding adds a finding for a hardcoded value, labelled or dropped by the reference and placeholder
+// filter like the pattern matches, so "<password>" or "changeme" defaults are not reported as secrets
+func appendLiteralFinding(findings []TemplateFinding, patternMatcher *pattern.Patterns, rule string, path string, value string) []TemplateFinding {
+	rule, keep := patternMatcher.FilterValue(rule, value)
+	if !keep {
+		return findings
+	}
+	return append(findings, TemplateFinding{Rule: rule, Path: path, Value: value})
+}
+
+// literalString returns the value when it is a hardcoded scalar rather than an intrinsic or a dynamic secret reference
+func literalString(value interface{}) (string, bool) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case map[string]interface{}:
+		// !Sub without any substitution is still a literal
+		sub, ok := v["Fn::Sub"].(string)
+		if !ok || len(v) != 1 || strings.Contains(sub, "${") {
+			return "", false
+		}
+		s = sub
+	case nil, []interface{}:
+		return "", false
+	default:
+		s = fmt.Sprint(v)
+	}
+	if dynamicSecretPattern.MatchString(s) {
+		return "", false
+	}
+	return s, true
+}
+
+func isTrue(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return strings.EqualFold(v, "true")
+	}
+	return false
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// deployedParameterValue looks up the value a stack was deployed with, CloudFormation masks NoEcho parameters as ****
+func deployedParameterValue(parameters []cfTypes.Parameter, path string) string {
+	name := strings.TrimPrefix(path, "Parameters.")
+	for _, param := range parameters {
+		if aws.ToString(param.ParameterKey) == name {
+			return aws.ToString(param.ParameterValue)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	"awsecrets/pattern"
+)
+
+func loadTestPatterns(t *testing.T) *pattern.Patterns {
+	t.Helper()
+	patternMatcher, err := pattern.LoadPatterns("../pattern/content.json")
+	if err != nil {
+		t.Fatalf("LoadPatterns() error = %v", err)
+	}
+	return patternMatcher
+}
+
+func TestAnalyzeTemplate(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	tests := []struct {
+		name string
+		body string
+		want []TemplateFinding
+	}{
+		{
+			name: "credential parameter without NoEcho and with default",
+			body: "Parameters:\n  DBPassword:\n    Type: String\n    Default: Hunter2\n  Env:\n    Type: String\n",
+			want: []TemplateFinding{
+				{Rule: "Credential Parameter Without NoEcho", Path: "Parameters.DBPassword"},
+				{Rule: "Credential Parameter With Default Value", Path: "Parameters.DBPassword.Default", Value: "Hunter2"},
+			},
+		},
+		{
+			name: "NoEcho parameter without default",
+			body: `{"Parameters": {"ApiToken": {"Type": "String", "NoEcho": "true"}}}`,
+			want: nil,
+		},
+		{
+			name: "hardcoded sensitive property",
+			body: "Resources:\n  DB:\n    Type: AWS::RDS::DBInstance\n    Properties:\n      MasterUserPassword: Hunter2\n",
+			want: []TemplateFinding{
+				{Rule: "Hardcoded Sensitive Property", Path: "Resources.DB.Properties.MasterUserPassword", Value: "Hunter2"},
+			},
+		},
+		{
+			name: "references are not literals",
+			body: "Resources:\n  DB:\n    Properties:\n      MasterUserPassword: !Ref DBPassword\n" +
+				"      Password: '{{resolve:secretsmanager:db:SecretString:password}}'\n" +
+				"      AuthToken: !Sub '${Token}'\n",
+			want: nil,
+		},
+		{
+			name: "!Sub without substitution is a literal",
+			body: "Resources:\n  DB:\n    Properties:\n      MasterUserPassword: !Sub Hunter2\n",
+			want: []TemplateFinding{
+				{Rule: "Hardcoded Sensitive Property", Path: "Resources.DB.Properties.MasterUserPassword", Value: "Hunter2"},
+			},
+		},
+		{
+			name: "function environment variables",
+			body: "Resources:\n  Fn:\n    Properties:\n      Environment:\n        Variables:\n          DB_PASSWORD: Hunter2\n          STAGE: prod\n          TABLE: !Ref Table\n",
+			want: []TemplateFinding{
+				{Rule: "Hardcoded Environment Variable", Path: "Resources.Fn.Properties.Environment.Variables.DB_PASSWORD", Value: "Hunter2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AnalyzeTemplate(tt.body, patternMatcher, "MatchString")
+			if err != nil {
+				t.Fatalf("AnalyzeTemplate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AnalyzeTemplate() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeTemplateFilter(t *testing.T) {
+	body := "Parameters:\n  DBPassword:\n    Type: String\n    NoEcho: true\n    Default: '<password>'\n" +
+		"Resources:\n  DB:\n    Properties:\n      MasterUserPassword: changeme\n      Environment:\n        Variables:\n          API_TOKEN: Hunter2\n"
+	tests := []struct {
+		mode string
+		want []TemplateFinding
+	}{
+		{
+			mode: pattern.FilterInfo,
+			want: []TemplateFinding{
+				{Rule: "Credential Parameter With Default Value [informational: placeholder]", Path: "Parameters.DBPassword.Default", Value: "<password>"},
+				{Rule: "Hardcoded Environment Variable", Path: "Resources.DB.Properties.Environment.Variables.API_TOKEN", Value: "Hunter2"},
+				{Rule: "Hardcoded Sensitive Property [informational: placeholder]", Path: "Resources.DB.Properties.MasterUserPassword", Value: "changeme"},
+			},
+		},
+		{
+			mode: pattern.FilterDrop,
+			want: []TemplateFinding{
+				{Rule: "Hardcoded Environment Variable", Path: "Resources.DB.Properties.Environment.Variables.API_TOKEN", Value: "Hunter2"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			patternMatcher := loadTestPatterns(t)
+			filter, err := pattern.NewFilter(tt.mode, "")
+			if err != nil {
+				t.Fatalf("NewFilter() error = %v", err)
+			}
+			patternMatcher.Filter = filter
+			got, err := AnalyzeTemplate(body, patternMatcher, "MatchString")
+			if err != nil {
+				t.Fatalf("AnalyzeTemplate() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AnalyzeTemplate() = %#v, want %#v", got, tt.want)
+			}
+			wantCounts := map[string]map[string]int{pattern.ClassPlaceholder: {"Credential Parameter With Default Value": 1, "Hardcoded Sensitive Property": 1}}
+			if counts := filter.Counts(); !reflect.DeepEqual(counts, wantCounts) {
+				t.Errorf("Counts() = %v, want %v", counts, wantCounts)
+			}
+		})
+	}
+}
+
+func TestLiteralString(t *testing.T) {
+	tests := []struct {
+		name   string
+		value  interface{}
+		want   string
+		wantOK bool
+	}{
+		{"string", "Hunter2", "Hunter2", true},
+		{"number", 1234, "1234", true},
+		{"ref", map[string]interface{}{"Ref": "Password"}, "", false},
+		{"sub with placeholder", map[string]interface{}{"Fn::Sub": "${Password}"}, "", false},
+		{"sub without placeholder", map[string]interface{}{"Fn::Sub": "Hunter2"}, "Hunter2", true},
+		{"ssm-secure", "{{resolve:ssm-secure:/db/password:1}}", "", false},
+		{"nil", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := literalString(tt.value)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("literalString() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"awsecrets/formatting"
@@ -182,10 +183,13 @@ func FetchStackSets(ctx context.Context, cfClient *cloudformation.Client, thread
 func ProcessCloudFormation(stacks []StackData, stackSets []StackSetData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
 	for _, stack := range stacks {
 		matches := patternMatcher.MatchPatterns(stack.TemplateBody, matchMode)
-		if len(matches) > 0 {
+		findings := analyzeStackTemplate(stack.StackName, stack.TemplateBody, stack.Parameters, patternMatcher, matchMode)
+		if len(matches) > 0 || len(findings) > 0 {
 			//formatting.StackName(stack.StackName)
 			formatting.Title("CloudFormation Stack", stack.StackName)
-			for patternName, matchedStrings := range matches {
+			printTemplateFindings(findings, showContent)
+			for _, patternName := range formatting.SortedPatterns(matches) {
+				matchedStrings := matches[patternName]
 				for _, match := range matchedStrings {
 					formatting.PatterName(patternName)
 					formatting.Content(match, showContent)
@@ -201,13 +205,15 @@ func ProcessCloudFormation(stacks []StackData, stackSets []StackSetData, pattern
 					formatting.CloudformationParameter()
 					//formatting.PatterName(paramKey)
 					//formatting.Content(paramValue, showContent)
-					for patternName, matchedStrings := range matchesKey {
+					for _, patternName := range formatting.SortedPatterns(matchesKey) {
+						matchedStrings := matchesKey[patternName]
 						for _, match := range matchedStrings {
 							formatting.PatterName(patternName)
 							formatting.Content(match, showContent)
 						}
 					}
-					for patternName, matchedStrings := range matchesValue {
+					for _, patternName := range formatting.SortedPatterns(matchesValue) {
+						matchedStrings := matchesValue[patternName]
 						for _, match := range matchedStrings {
 							formatting.PatterName(patternName)
 							formatting.Content(match, showContent)
@@ -221,10 +227,13 @@ func ProcessCloudFormation(stacks []StackData, stackSets []StackSetData, pattern
 
 	for _, stackSet := range stackSets {
 		matches := patternMatcher.MatchPatterns(stackSet.TemplateBody, matchMode)
-		if len(matches) > 0 {
+		findings := analyzeStackTemplate(stackSet.StackSetName, stackSet.TemplateBody, stackSet.Parameters, patternMatcher, matchMode)
+		if len(matches) > 0 || len(findings) > 0 {
 			//formatting.StackSetName(stackSet.StackSetName)
 			formatting.Title("CloudFormation Stack Set", stackSet.StackSetName)
-			for patternName, matchedStrings := range matches {
+			printTemplateFindings(findings, showContent)
+			for _, patternName := range formatting.SortedPatterns(matches) {
+				matchedStrings := matches[patternName]
 				for _, match := range matchedStrings {
 					formatting.PatterName(patternName)
 					formatting.Content(match, showContent)
@@ -241,3 +250,28 @@ func ProcessCloudFormation(stacks []StackData, stackSets []StackSetData, pattern
 		}
 	}
 }
+
+func analyzeStackTemplate(name string, templateBody string, parameters []cfTypes.Parameter, patternMatcher *pattern.Patterns, matchMode string) []TemplateFinding {
+	findings, err := AnalyzeTemplate(templateBody, patternMatcher, matchMode)
+	if err != nil {
+		log.Printf("Failed to parse template for %s: %v", name, err)
+		return nil
+	}
+	// Parameters deployed without NoEcho are returned in clear text by DescribeStacks
+	for i, finding := range findings {
+		if finding.Value == "" && strings.HasPrefix(finding.Path, "Parameters.") {
+			findings[i].Value = deployedParameterValue(parameters, finding.Path)
+		}
+	}
+	return findings
+}
+
+func printTemplateFindings(findings []TemplateFinding, showContent bool) {
+	for _, finding := range findings {
+		formatting.PatterName(finding.Rule)
+		formatting.Data("Template Path", finding.Path)
+		if finding.Value != "" {
+			formatting.Content(finding.Value, showContent)
+		}
+	}
+}
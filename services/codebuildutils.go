@@ -6,10 +6,13 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/codebuild"
+	"gopkg.in/yaml.v3"
 )
 
 type CodeBuildProjectData struct {
@@ -84,7 +87,7 @@ func ProcessCodeBuildProjects(projects []CodeBuildProjectData, patternMatcher *p
 		var hasMatches bool
 		matchesInSource := patternMatcher.MatchPatterns(project.Source, matchMode)
 		matchesInEnvVars := matchEnvVariables(project.Environment, patternMatcher, matchMode)
-		matchesInBuildspec := patternMatcher.MatchPatterns(project.Buildspec, matchMode)
+		matchesInBuildspec := matchBuildspec(project.Buildspec, patternMatcher, matchMode)
 
 		if len(matchesInSource) > 0 || len(matchesInEnvVars) > 0 || len(matchesInBuildspec) > 0 {
 			hasMatches = true
@@ -110,3 +113,44 @@ func ProcessCodeBuildProjects(projects []CodeBuildProjectData, patternMatcher *p
 		}
 	}
 }
+
+// buildspec holds the parts of a buildspec that carry commands and plaintext variables
+type buildspec struct {
+	Env struct {
+		Variables map[string]string `yaml:"variables"`
+	} `yaml:"env"`
+	Phases map[string]struct {
+		Commands []string `yaml:"commands"`
+		Finally  []string `yaml:"finally"`
+	} `yaml:"phases"`
+}
+
+// matchBuildspec scans the whole buildspec with the pattern set, except the line rules the shell detector replaces,
+// runs phase commands through the shell detector and env variables through the key-name heuristics
+func matchBuildspec(content string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	var spec buildspec
+	if err := yaml.Unmarshal([]byte(content), &spec); err != nil || len(spec.Phases) == 0 {
+		return patternMatcher.MatchPatterns(content, matchMode)
+	}
+
+	matches := patternMatcher.MatchPatternsExcept(content, matchMode, pattern.ShellSupersededRules)
+	for key, value := range spec.Env.Variables {
+		for patternName, matchedStrings := range patternMatcher.MatchKeyValue("env.variables."+key, key, value) {
+			matches[patternName] = append(matches[patternName], matchedStrings...)
+		}
+	}
+	phaseNames := make([]string, 0, len(spec.Phases))
+	for name := range spec.Phases {
+		phaseNames = append(phaseNames, name)
+	}
+	sort.Strings(phaseNames)
+	for _, name := range phaseNames {
+		phase := spec.Phases[name]
+		commands := append(append([]string{}, phase.Commands...), phase.Finally...)
+		for patternName, matchedStrings := range patternMatcher.MatchShell(strings.Join(commands, "\n")) {
+			key := fmt.Sprintf("phases.%s: %s", name, patternName)
+			matches[key] = append(matches[key], matchedStrings...)
+		}
+	}
+	return matches
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// SkippedTooLarge is the status reported for objects larger than the download limit
+const SkippedTooLarge = "skipped: too large"
+
+// ErrTooLarge is returned when an object exceeds Downloader.MaxSize
+var ErrTooLarge = errors.New(SkippedTooLarge)
+
+// Default download limits, overridden with -max-download-size and -download-timeout
+const (
+	DefaultMaxDownloadSize = 256 << 20
+	DefaultDownloadTimeout = 2 * time.Minute
+)
+
+// SkippedObject is an object that was not scanned, Source names the resource it belongs to
+type SkippedObject struct {
+	Source string
+	Status string
+}
+
+// Downloader fetches deployment packages over HTTPS and scripts from S3 with a timeout and a size cap,
+// and remembers what it had to skip so the summary can report coverage
+type Downloader struct {
+	HTTPClient *http.Client
+	S3Client   *s3.Client
+	MaxSize    int64
+	Timeout    time.Duration
+
+	mu      sync.Mutex
+	skipped []SkippedObject
+}
+
+// Download is an object spooled to a temporary file, Close removes the file
+type Download struct {
+	*os.File
+	Size int64
+}
+
+// NewDownloader creates a Downloader, zero limits fall back to the defaults
+func NewDownloader(s3Client *s3.Client, maxSize int64, timeout time.Duration) *Downloader {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxDownloadSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultDownloadTimeout
+	}
+	return &Downloader{
+		HTTPClient: &http.Client{Timeout: timeout},
+		S3Client:   s3Client,
+		MaxSize:    maxSize,
+		Timeout:    timeout,
+	}
+}
+
+// Open downloads an https:// or s3:// object to a temporary file so archives can be read without holding them in memory
+func (d *Downloader) Open(ctx context.Context, source string, location string) (*Download, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	body, err := d.get(ctx, source, location)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	file, err := os.CreateTemp("", "awsecrets-*")
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(file, io.LimitReader(body, d.MaxSize+1))
+	if err == nil && size > d.MaxSize {
+		err = d.skip(source, -1)
+	}
+	if err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return &Download{File: file, Size: size}, nil
+}
+
+// Close closes and removes the temporary file
+func (d *Download) Close() error {
+	err := d.File.Close()
+	os.Remove(d.File.Name())
+	return err
+}
+
+// ReadText downloads a script or other small text object into memory
+func (d *Downloader) ReadText(ctx context.Context, source string, location string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	body, err := d.get(ctx, source, location)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(body, d.MaxSize+1))
+	if err != nil {
+		return "", err
+	}
+	if int64(len(content)) > d.MaxSize {
+		return "", d.skip(source, -1)
+	}
+	return string(content), nil
+}
+
+// get opens the object body, rejecting it early when the advertised length is over the limit
+func (d *Downloader) get(ctx context.Context, source string, location string) (io.ReadCloser, error) {
+	if strings.HasPrefix(location, "s3://") {
+		bucket, key, err := parseS3URL(location)
+		if err != nil {
+			return nil, err
+		}
+		if d.S3Client == nil {
+			return nil, fmt.Errorf("no S3 client to download %s", location)
+		}
+		result, err := d.S3Client.GetObject(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return nil, err
+		}
+		if size := aws.ToInt64(result.ContentLength); size > d.MaxSize {
+			result.Body.Close()
+			return nil, d.skip(source, size)
+		}
+		return result.Body, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > d.MaxSize {
+		resp.Body.Close()
+		return nil, d.skip(source, resp.ContentLength)
+	}
+	return resp.Body, nil
+}
+
+// skip records an object over the size limit and returns ErrTooLarge, size is -1 when the object was streamed
+func (d *Downloader) skip(source string, size int64) error {
+	status := fmt.Sprintf("%s (over the %d MiB limit)", SkippedTooLarge, d.MaxSize>>20)
+	if size >= 0 {
+		status = fmt.Sprintf("%s (%d MiB, limit %d MiB)", SkippedTooLarge, size>>20, d.MaxSize>>20)
+	}
+	d.mu.Lock()
+	d.skipped = append(d.skipped, SkippedObject{Source: source, Status: status})
+	d.mu.Unlock()
+	return ErrTooLarge
+}
+
+// Skipped returns the objects that were not scanned because of the size limit
+func (d *Downloader) Skipped() []SkippedObject {
+	if d == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]SkippedObject(nil), d.skipped...)
+}
+
+func parseS3URL(location string) (string, string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(location, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid S3 URL: %s", location)
+	}
+	return parts[0], parts[1], nil
+}
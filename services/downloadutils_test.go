@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseS3URL(t *testing.T) {
+	tests := []struct {
+		location   string
+		wantBucket string
+		wantKey    string
+		wantErr    bool
+	}{
+		{"s3://bucket/scripts/job.py", "bucket", "scripts/job.py", false},
+		{"s3://bucket/", "", "", true},
+		{"s3://bucket", "", "", true},
+		{"s3:///key", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			bucket, key, err := parseS3URL(tt.location)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseS3URL() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if bucket != tt.wantBucket || key != tt.wantKey {
+				t.Errorf("parseS3URL() = %q, %q, want %q, %q", bucket, key, tt.wantBucket, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestNewDownloaderDefaults(t *testing.T) {
+	d := NewDownloader(nil, 0, 0)
+	if d.MaxSize != DefaultMaxDownloadSize || d.Timeout != DefaultDownloadTimeout {
+		t.Errorf("NewDownloader() = %d, %v, want %d, %v", d.MaxSize, d.Timeout, DefaultMaxDownloadSize, DefaultDownloadTimeout)
+	}
+}
+
+func TestDownloader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small":
+			io.WriteString(w, "password = x")
+		case "/large":
+			io.WriteString(w, strings.Repeat("a", 64))
+		case "/streamed":
+			// Flushing before the body is written drops the Content-Length header
+			w.(http.Flusher).Flush()
+			io.WriteString(w, strings.Repeat("a", 64))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name        string
+		path        string
+		want        string
+		wantErr     bool
+		wantSkipped []SkippedObject
+	}{
+		{name: "within the limit", path: "/small", want: "password = x"},
+		{
+			name:        "advertised length over the limit",
+			path:        "/large",
+			wantErr:     true,
+			wantSkipped: []SkippedObject{{Source: "fn", Status: SkippedTooLarge + " (0 MiB, limit 0 MiB)"}},
+		},
+		{
+			name:        "streamed body over the limit",
+			path:        "/streamed",
+			wantErr:     true,
+			wantSkipped: []SkippedObject{{Source: "fn", Status: SkippedTooLarge + " (over the 0 MiB limit)"}},
+		},
+		{name: "error status", path: "/missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, open := range []bool{false, true} {
+				d := NewDownloader(nil, 32, 0)
+				var got string
+				var err error
+				if open {
+					var download *Download
+					if download, err = d.Open(context.Background(), "fn", server.URL+tt.path); err == nil {
+						data, _ := io.ReadAll(io.NewSectionReader(download, 0, download.Size))
+						got = string(data)
+						download.Close()
+					}
+				} else {
+					got, err = d.ReadText(context.Background(), "fn", server.URL+tt.path)
+				}
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("open = %v: error = %v, wantErr %v", open, err, tt.wantErr)
+				}
+				if tt.wantSkipped != nil && !errors.Is(err, ErrTooLarge) {
+					t.Errorf("open = %v: error = %v, want ErrTooLarge", open, err)
+				}
+				if got != tt.want {
+					t.Errorf("open = %v: content = %q, want %q", open, got, tt.want)
+				}
+				if skipped := d.Skipped(); !reflect.DeepEqual(skipped, tt.wantSkipped) {
+					t.Errorf("open = %v: Skipped() = %v, want %v", open, skipped, tt.wantSkipped)
+				}
+			}
+		})
+	}
+}
+
+func TestDownloaderS3WithoutClient(t *testing.T) {
+	d := NewDownloader(nil, 0, 0)
+	if _, err := d.ReadText(context.Background(), "job", "s3://bucket/job.py"); err == nil {
+		t.Errorf("ReadText() error = nil, want an error without an S3 client")
+	}
+	var nilDownloader *Downloader
+	if skipped := nilDownloader.Skipped(); skipped != nil {
+		t.Errorf("Skipped() = %v, want nil", skipped)
+	}
+}
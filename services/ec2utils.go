@@ -114,15 +114,10 @@ func FetchInstances(ctx context.Context, ec2client *ec2.Client, threads int) ([]
 
 func ProcessInstances(instances []InstanceData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
 	for _, instance := range instances {
-		matches := patternMatcher.MatchPatterns(instance.UserData, matchMode)
+		matches := matchUserData(instance.UserData, patternMatcher, matchMode)
 		if len(matches) > 0 {
 			formatting.Title("Instance ID", instance.InstanceID)
-			for patterName, matchedStrings := range matches {
-				for _, match := range matchedStrings {
-					formatting.PatterName(patterName)
-					formatting.Content(match, showContent)
-				}
-			}
+			printUserDataMatches(matches, showContent)
 		}
 	}
 	fmt.Println()
@@ -231,17 +226,17 @@ func ProcessLaunchTemplates(templates []LaunchTemplateData, patternMatcher *patt
 	for templateName, versions := range templatesByName {
 		var templateMatches []struct {
 			Version int64
-			Matches map[string][]string
+			Matches []userDataMatch
 		}
 
 		// Check each version for matches
 		for _, template := range versions {
-			matches := patternMatcher.MatchPatterns(template.UserData, matchMode)
+			matches := matchUserData(template.UserData, patternMatcher, matchMode)
 			if len(matches) > 0 {
 				// Collect versions with matches
 				templateMatches = append(templateMatches, struct {
 					Version int64
-					Matches map[string][]string
+					Matches []userDataMatch
 				}{
 					Version: template.Version,
 					Matches: matches,
@@ -257,12 +252,7 @@ func ProcessLaunchTemplates(templates []LaunchTemplateData, patternMatcher *patt
 				// Print the version information
 				//formatting.LaunchTemplateData("Version", strconv.Itoa(tm.Version))
 				formatting.Data("Version", strconv.Itoa(int(tm.Version)))
-				for patternName, matchedStrings := range tm.Matches {
-					for _, match := range matchedStrings {
-						formatting.PatterName(patternName)
-						formatting.Content(match, showContent)
-					}
-				}
+				printUserDataMatches(tm.Matches, showContent)
 			}
 			fmt.Println() // Add an empty line between different launch templates
 		}
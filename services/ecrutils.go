@@ -0,0 +1,261 @@
+package services
+
+import (
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
+)
+
+type ECRImageData struct {
+	Repository  string
+	Tags        []string
+	Digest      string
+	PushedAt    time.Time
+	ConfigFiles []SourceFile
+	Layers      []*ImageLayer
+}
+
+// ECRScanOptions selects how many images per repository are scanned and whether layer contents are extracted
+type ECRScanOptions struct {
+	Tags   int
+	Layers bool
+	Files  FileFilter
+}
+
+// ecrImageRef is an image to scan, host is empty when the registry endpoint is overridden
+type ecrImageRef struct {
+	host       string
+	repository string
+	reference  string
+	tags       []string
+	pushedAt   time.Time
+}
+
+// FetchECRImages lists repositories and their most recent tagged images, and fetches each image's config and,
+// optionally, layers. With a registry endpoint override the OCI catalog and tag list APIs replace ECR
+func FetchECRImages(ctx context.Context, ecrClient *ecr.Client, registry *Registry, threads int, options ECRScanOptions) ([]ECRImageData, error) {
+	var refs []ecrImageRef
+	var err error
+	if registry.Endpoint != "" {
+		refs, err = listRegistryImages(ctx, registry, options.Tags)
+	} else {
+		refs, err = listECRImages(ctx, ecrClient, options.Tags)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var images []ECRImageData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+	filter := imageFilter(options.Files)
+
+	for _, ref := range refs {
+		wg.Add(1)
+		go func(ref ecrImageRef) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			image := ECRImageData{Repository: ref.repository, Tags: ref.tags, PushedAt: ref.pushedAt}
+			manifest, digest, err := registry.Manifest(ctx, ref.host, ref.repository, ref.reference)
+			if err != nil {
+				log.Printf("Failed to fetch manifest for %s:%s: %v", ref.repository, ref.reference, err)
+				return
+			}
+			image.Digest = digest
+
+			config, err := registry.Config(ctx, ref.host, ref.repository, manifest)
+			if err != nil {
+				log.Printf("Failed to fetch image config for %s@%s: %v", ref.repository, digest, err)
+			} else {
+				image.ConfigFiles = imageConfigFiles(config)
+			}
+
+			if options.Layers {
+				for _, descriptor := range manifest.Layers {
+					layer, _ := registry.Layer(ctx, ref.host, ref.repository, descriptor, filter)
+					image.Layers = append(image.Layers, layer)
+				}
+			}
+
+			mu.Lock()
+			images = append(images, image)
+			mu.Unlock()
+		}(ref)
+	}
+
+	wg.Wait()
+	return images, nil
+}
+
+// listECRImages returns the most recently pushed tagged images of every repository
+func listECRImages(ctx context.Context, ecrClient *ecr.Client, tags int) ([]ecrImageRef, error) {
+	var refs []ecrImageRef
+	paginator := ecr.NewDescribeRepositoriesPaginator(ecrClient, &ecr.DescribeRepositoriesInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, repository := range page.Repositories {
+			repositoryName := aws.ToString(repository.RepositoryName)
+			host, _, _, err := ParseImageReference(aws.ToString(repository.RepositoryUri))
+			if err != nil {
+				log.Printf("Failed to parse repository URI for %s: %v", repositoryName, err)
+				continue
+			}
+
+			var details []ecrTypes.ImageDetail
+			imagePaginator := ecr.NewDescribeImagesPaginator(ecrClient, &ecr.DescribeImagesInput{
+				RepositoryName: aws.String(repositoryName),
+				RegistryId:     repository.RegistryId,
+				Filter:         &ecrTypes.DescribeImagesFilter{TagStatus: ecrTypes.TagStatusTagged},
+			})
+			for imagePaginator.HasMorePages() {
+				imagePage, err := imagePaginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to describe images for repository %s: %v", repositoryName, err)
+					break
+				}
+				details = append(details, imagePage.ImageDetails...)
+			}
+
+			sort.SliceStable(details, func(i, j int) bool {
+				return aws.ToTime(details[i].ImagePushedAt).After(aws.ToTime(details[j].ImagePushedAt))
+			})
+			if tags >= 0 && len(details) > tags {
+				details = details[:tags]
+			}
+			for _, detail := range details {
+				refs = append(refs, ecrImageRef{
+					host:       host,
+					repository: repositoryName,
+					reference:  aws.ToString(detail.ImageDigest),
+					tags:       detail.ImageTags,
+					pushedAt:   aws.ToTime(detail.ImagePushedAt),
+				})
+			}
+		}
+	}
+	return refs, nil
+}
+
+// listRegistryImages lists a plain OCI registry, which has no push dates: the last tags returned are used
+func listRegistryImages(ctx context.Context, registry *Registry, tags int) ([]ecrImageRef, error) {
+	repositories, err := registry.Catalog(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	var refs []ecrImageRef
+	for _, repository := range repositories {
+		repositoryTags, err := registry.Tags(ctx, "", repository)
+		if err != nil {
+			log.Printf("Failed to list tags for repository %s: %v", repository, err)
+			continue
+		}
+		if tags >= 0 && len(repositoryTags) > tags {
+			repositoryTags = repositoryTags[len(repositoryTags)-tags:]
+		}
+		for _, tag := range repositoryTags {
+			refs = append(refs, ecrImageRef{repository: repository, reference: tag, tags: []string{tag}})
+		}
+	}
+	return refs, nil
+}
+
+func ProcessECRImages(images []ECRImageData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	// Newest images first, so layers shared across images are reported under the most recent one
+	sort.SliceStable(images, func(i, j int) bool {
+		if !images[i].PushedAt.Equal(images[j].PushedAt) {
+			return images[i].PushedAt.After(images[j].PushedAt)
+		}
+		if images[i].Repository != images[j].Repository {
+			return images[i].Repository < images[j].Repository
+		}
+		return strings.Join(images[i].Tags, ",") > strings.Join(images[j].Tags, ",")
+	})
+
+	// The same manifest pulled under several tags or repositories is reported once with all its names
+	tagsByDigest := make(map[string][]string)
+	for _, image := range images {
+		for _, tag := range image.Tags {
+			tagsByDigest[image.Digest] = append(tagsByDigest[image.Digest], image.Repository+":"+tag)
+		}
+	}
+
+	seenImages := make(map[string]bool)
+	seenLayers := make(map[string]bool)
+	for _, image := range images {
+		if seenImages[image.Digest] {
+			continue
+		}
+		seenImages[image.Digest] = true
+		name := fmt.Sprintf("%s@%s", image.Repository, shortDigest(image.Digest))
+
+		configMatches := make(map[string][]string)
+		for _, file := range image.ConfigFiles {
+			for patternName, matchedStrings := range matchSourceFile(file, patternMatcher, matchMode) {
+				configMatches[patternName] = append(configMatches[patternName], matchedStrings...)
+			}
+		}
+
+		var layerMatches []map[string][]string
+		var layerNames, layerStatuses []string
+		for _, layer := range image.Layers {
+			layerName := shortDigest(layer.Digest)
+			// Layers shared by several images, such as base image layers, are reported once
+			if seenLayers[layer.Digest] {
+				continue
+			}
+			seenLayers[layer.Digest] = true
+
+			if layer.Status != "" {
+				layerStatuses = append(layerStatuses, fmt.Sprintf("layer %s %s", layerName, layer.Status))
+			}
+			matches := make(map[string][]string)
+			for _, file := range layer.Files {
+				for patternName, matchedStrings := range matchSourceFile(file, patternMatcher, matchMode) {
+					matches[patternName] = append(matches[patternName], matchedStrings...)
+				}
+			}
+			if len(matches) > 0 {
+				layerMatches = append(layerMatches, matches)
+				layerNames = append(layerNames, layerName)
+			}
+		}
+
+		if len(configMatches) == 0 && len(layerMatches) == 0 && len(layerStatuses) == 0 {
+			continue
+		}
+
+		formatting.Title("ECR Image", name)
+		if tags := tagsByDigest[image.Digest]; len(tags) > 0 {
+			formatting.Data("Tags", strings.Join(tags, ", "))
+		}
+		for _, status := range layerStatuses {
+			formatting.Data("Status", status)
+		}
+		if len(configMatches) > 0 {
+			formatting.FuncCodeDetails("Image Config", configMatches, showContent)
+		}
+		for i, matches := range layerMatches {
+			formatting.Data("Layer", layerNames[i])
+			formatting.FuncCodeDetails(layerNames[i], matches, showContent)
+		}
+		fmt.Println()
+	}
+}
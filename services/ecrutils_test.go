@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newTestRegistry serves a catalog with an "app" repository tagged v1, v2 and v3, every tag pointing to the same image
+func newTestRegistry(t *testing.T) *Registry {
+	t.Helper()
+	var config ImageConfig
+	config.Config.Env = []string{"DB_PASSWORD=Hunter2"}
+	manifest := ImageManifest{MediaType: mediaTypeOCIManifest, Config: Descriptor{Digest: "sha256:config"}}
+	responses := map[string]interface{}{
+		"/v2/_catalog":                map[string][]string{"repositories": {"app"}},
+		"/v2/app/tags/list":           map[string][]string{"tags": {"v1", "v2", "v3"}},
+		"/v2/app/blobs/sha256:config": config,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/v2/app/manifests/") {
+			json.NewEncoder(w).Encode(manifest)
+			return
+		}
+		response, ok := responses[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return NewRegistry(nil, server.URL, NewDownloader(nil, 0, 0))
+}
+
+func TestListRegistryImages(t *testing.T) {
+	registry := newTestRegistry(t)
+	tests := []struct {
+		name string
+		tags int
+		want []string
+	}{
+		{"all tags", -1, []string{"v1", "v2", "v3"}},
+		{"last tags", 2, []string{"v2", "v3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			refs, err := listRegistryImages(context.Background(), registry, tt.tags)
+			if err != nil {
+				t.Fatalf("listRegistryImages() error = %v", err)
+			}
+			var got []string
+			for _, ref := range refs {
+				if ref.repository != "app" || ref.host != "" {
+					t.Errorf("listRegistryImages() ref = %+v, want repository app without a host", ref)
+				}
+				got = append(got, ref.reference)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("listRegistryImages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchECRImagesFromRegistryEndpoint(t *testing.T) {
+	registry := newTestRegistry(t)
+	images, err := FetchECRImages(context.Background(), nil, registry, 2, ECRScanOptions{Tags: 2})
+	if err != nil {
+		t.Fatalf("FetchECRImages() error = %v", err)
+	}
+	var tags []string
+	for _, image := range images {
+		tags = append(tags, image.Tags...)
+		if !strings.HasPrefix(image.Digest, "sha256:") || image.Digest != images[0].Digest {
+			t.Errorf("FetchECRImages() digest = %q, want the manifest digest shared by every tag", image.Digest)
+		}
+		wantFiles := []SourceFile{{Path: "image config/.env", Content: "DB_PASSWORD=Hunter2"}}
+		if !reflect.DeepEqual(image.ConfigFiles, wantFiles) {
+			t.Errorf("FetchECRImages() config files = %#v, want %#v", image.ConfigFiles, wantFiles)
+		}
+	}
+	sort.Strings(tags)
+	if want := []string{"v2", "v3"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("FetchECRImages() tags = %v, want %v", tags, want)
+	}
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecsTypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+type ECSTaskDefinitionData struct {
+	Family     string
+	Revision   int32
+	Status     string
+	Containers []ECSContainerData
+}
+
+// ECSContainerData holds the container definition fields that can carry plaintext secrets. The secrets field
+// references SSM Parameter Store or Secrets Manager through valueFrom and is not scanned
+type ECSContainerData struct {
+	Name                  string
+	Environment           map[string]string
+	Command               []string
+	EntryPoint            []string
+	DockerLabels          map[string]string
+	RepositoryCredentials string
+}
+
+// FetchECSTaskDefinitions walks every task definition family and its revisions, inactive revisions are included on request
+func FetchECSTaskDefinitions(ctx context.Context, ecsClient *ecs.Client, threads int, includeInactive bool) ([]ECSTaskDefinitionData, error) {
+	statuses := []ecsTypes.TaskDefinitionStatus{ecsTypes.TaskDefinitionStatusActive}
+	familyStatus := ecsTypes.TaskDefinitionFamilyStatusActive
+	if includeInactive {
+		statuses = append(statuses, ecsTypes.TaskDefinitionStatusInactive)
+		familyStatus = ecsTypes.TaskDefinitionFamilyStatusAll
+	}
+
+	var families []string
+	paginator := ecs.NewListTaskDefinitionFamiliesPaginator(ecsClient, &ecs.ListTaskDefinitionFamiliesInput{
+		Status: familyStatus,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		families = append(families, page.Families...)
+	}
+
+	var taskDefinitions []ECSTaskDefinitionData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	for _, family := range families {
+		for _, status := range statuses {
+			definitionPaginator := ecs.NewListTaskDefinitionsPaginator(ecsClient, &ecs.ListTaskDefinitionsInput{
+				FamilyPrefix: aws.String(family),
+				Status:       status,
+				Sort:         ecsTypes.SortOrderDesc,
+			})
+			for definitionPaginator.HasMorePages() {
+				page, err := definitionPaginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to list task definitions for family %s: %v", family, err)
+					break
+				}
+				for _, arn := range page.TaskDefinitionArns {
+					// FamilyPrefix also matches longer family names
+					if taskDefinitionFamily(arn) != family {
+						continue
+					}
+					wg.Add(1)
+					go func(arn string) {
+						semaphore <- struct{}{}
+						defer func() {
+							<-semaphore
+							wg.Done()
+						}()
+
+						output, err := ecsClient.DescribeTaskDefinition(ctx, &ecs.DescribeTaskDefinitionInput{
+							TaskDefinition: aws.String(arn),
+						})
+						if err != nil {
+							log.Printf("Failed to describe task definition %s: %v", arn, err)
+							return
+						}
+
+						mu.Lock()
+						taskDefinitions = append(taskDefinitions, newECSTaskDefinitionData(output.TaskDefinition))
+						mu.Unlock()
+					}(arn)
+				}
+			}
+		}
+	}
+
+	wg.Wait()
+	return taskDefinitions, nil
+}
+
+// taskDefinitionFamily extracts the family from arn:aws:ecs:region:account:task-definition/family:revision
+func taskDefinitionFamily(arn string) string {
+	name := arn[strings.LastIndex(arn, "/")+1:]
+	if colon := strings.LastIndex(name, ":"); colon >= 0 {
+		name = name[:colon]
+	}
+	return name
+}
+
+func newECSTaskDefinitionData(taskDefinition *ecsTypes.TaskDefinition) ECSTaskDefinitionData {
+	data := ECSTaskDefinitionData{
+		Family:   aws.ToString(taskDefinition.Family),
+		Revision: taskDefinition.Revision,
+		Status:   string(taskDefinition.Status),
+	}
+	for _, container := range taskDefinition.ContainerDefinitions {
+		environment := make(map[string]string)
+		for _, pair := range container.Environment {
+			environment[aws.ToString(pair.Name)] = aws.ToString(pair.Value)
+		}
+		containerData := ECSContainerData{
+			Name:         aws.ToString(container.Name),
+			Environment:  environment,
+			Command:      container.Command,
+			EntryPoint:   container.EntryPoint,
+			DockerLabels: container.DockerLabels,
+		}
+		if container.RepositoryCredentials != nil {
+			containerData.RepositoryCredentials = aws.ToString(container.RepositoryCredentials.CredentialsParameter)
+		}
+		data.Containers = append(data.Containers, containerData)
+	}
+	return data
+}
+
+func ProcessECSTaskDefinitions(taskDefinitions []ECSTaskDefinitionData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(taskDefinitions, func(i, j int) bool {
+		if taskDefinitions[i].Family != taskDefinitions[j].Family {
+			return taskDefinitions[i].Family < taskDefinitions[j].Family
+		}
+		return taskDefinitions[i].Revision > taskDefinitions[j].Revision
+	})
+
+	for _, taskDefinition := range taskDefinitions {
+		var printed bool
+		for _, container := range taskDefinition.Containers {
+			matches := matchECSContainer(container, patternMatcher, matchMode)
+			if len(matches) == 0 {
+				continue
+			}
+			if !printed {
+				formatting.Title("ECS Task Definition", fmt.Sprintf("%s:%d", taskDefinition.Family, taskDefinition.Revision))
+				if taskDefinition.Status != string(ecsTypes.TaskDefinitionStatusActive) {
+					formatting.Data("Status", taskDefinition.Status)
+				}
+				printed = true
+			}
+			formatting.Data("Container", container.Name)
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		if printed {
+			fmt.Println()
+		}
+	}
+}
+
+// matchECSContainer scans the environment and docker labels with the pattern set and key-name heuristics,
+// and the command and entry point as a shell command
+func matchECSContainer(container ECSContainerData, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	matches := make(map[string][]string)
+	merge := func(prefix string, found map[string][]string) {
+		for patternName, matchedStrings := range found {
+			key := prefix + ": " + patternName
+			matches[key] = append(matches[key], matchedStrings...)
+		}
+	}
+
+	for name, value := range container.Environment {
+		merge("environment", matchNamedValue(name, value, patternMatcher, matchMode))
+	}
+	for name, value := range container.DockerLabels {
+		merge("dockerLabels", matchNamedValue(name, value, patternMatcher, matchMode))
+	}
+
+	if command := append(append([]string(nil), container.EntryPoint...), container.Command...); len(command) > 0 {
+		merge("command", patternMatcher.MatchShellScript(commandScript(command), matchMode))
+	}
+	if container.RepositoryCredentials != "" {
+		// Expected to be a Secrets Manager ARN
+		merge("repositoryCredentials", patternMatcher.MatchPatterns(container.RepositoryCredentials, matchMode))
+	}
+	return matches
+}
+
+// matchNamedValue reports a variable once: under the pattern that matched its value, or else through the
+// key-name heuristics applied to its name
+func matchNamedValue(name string, value string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	matches := make(map[string][]string)
+	for patternName, matchedStrings := range patternMatcher.MatchPatterns(value, matchMode) {
+		key := fmt.Sprintf("%s: %s", name, patternName)
+		matches[key] = append(matches[key], matchedStrings...)
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+	return patternMatcher.MatchKeyValue(name, name, value)
+}
+
+// matchValueOrName applies the same rule to values reported under a location of their own, such as an SSM
+// parameter: pattern names are not prefixed, and each item of a list goes through the key-name heuristics
+func matchValueOrName(location string, name string, values []string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	matches := patternMatcher.MatchPatterns(strings.Join(values, "\n"), matchMode)
+	if len(matches) > 0 {
+		return matches
+	}
+	for _, value := range values {
+		for patternName, matchedStrings := range patternMatcher.MatchKeyValue(location, name, value) {
+			matches[patternName] = append(matches[patternName], matchedStrings...)
+		}
+	}
+	return matches
+}
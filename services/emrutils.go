@@ -5,27 +5,32 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
 	"strings"
 	"time"
 
 	"awsecrets/formatting"
 	"awsecrets/pattern"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/emr"
 	"github.com/aws/aws-sdk-go-v2/service/emr/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/smithy-go"
 )
 
 type EMRClusterData struct {
-	ClusterID               string
-	Steps                   []types.StepSummary
-	BootstrapActions        []types.Command
-	BootstrapScriptContents []string
+	ClusterID                 string
+	Steps                     []types.StepSummary
+	BootstrapActions          []types.Command
+	BootstrapScriptContents   []string
+	Configurations            []types.Configuration
+	SecurityConfiguration     string
+	SecurityConfigurationJSON string
 }
 
-func FetchEMRClusters(ctx context.Context, emrClient *emr.Client, s3Client *s3.Client, threads int) ([]EMRClusterData, error) {
+func FetchEMRClusters(ctx context.Context, emrClient *emr.Client, downloader *Downloader, threads int) ([]EMRClusterData, error) {
 	var clusters []EMRClusterData
+	securityConfigurations := make(map[string]string)
 
 	params := &emr.ListClustersInput{
 		ClusterStates: []types.ClusterState{
@@ -58,7 +63,7 @@ func FetchEMRClusters(ctx context.Context, emrClient *emr.Client, s3Client *s3.C
 			}
 			clusterData.Steps = steps
 
-			bootstrapActions, scriptContents, err := fetchBootstrapActions(ctx, emrClient, s3Client, *cluster.Id)
+			bootstrapActions, scriptContents, err := fetchBootstrapActions(ctx, emrClient, downloader, *cluster.Id)
 			if err != nil {
 				fmt.Printf("Error fetching bootstrap actions for cluster %s: %v\n", *cluster.Id, err)
 				continue
@@ -66,6 +71,21 @@ func FetchEMRClusters(ctx context.Context, emrClient *emr.Client, s3Client *s3.C
 			clusterData.BootstrapActions = bootstrapActions
 			clusterData.BootstrapScriptContents = scriptContents
 
+			describeOutput, err := emrClient.DescribeCluster(ctx, &emr.DescribeClusterInput{ClusterId: cluster.Id})
+			if err != nil {
+				fmt.Printf("Error describing cluster %s: %v\n", *cluster.Id, err)
+			} else {
+				clusterData.Configurations = describeOutput.Cluster.Configurations
+				clusterData.SecurityConfiguration = aws.ToString(describeOutput.Cluster.SecurityConfiguration)
+			}
+
+			if name := clusterData.SecurityConfiguration; name != "" {
+				if _, ok := securityConfigurations[name]; !ok {
+					securityConfigurations[name] = fetchSecurityConfiguration(ctx, emrClient, name)
+				}
+				clusterData.SecurityConfigurationJSON = securityConfigurations[name]
+			}
+
 			clusters = append(clusters, clusterData)
 		}
 	}
@@ -107,7 +127,7 @@ func fetchClusterSteps(ctx context.Context, emrClient *emr.Client, clusterID str
 	return resp.Steps, nil
 }
 
-func fetchBootstrapActions(ctx context.Context, emrClient *emr.Client, s3Client *s3.Client, clusterID string) ([]types.Command, []string, error) {
+func fetchBootstrapActions(ctx context.Context, emrClient *emr.Client, downloader *Downloader, clusterID string) ([]types.Command, []string, error) {
 	params := &emr.ListBootstrapActionsInput{
 		ClusterId: &clusterID,
 	}
@@ -141,7 +161,11 @@ func fetchBootstrapActions(ctx context.Context, emrClient *emr.Client, s3Client
 	var scriptContents []string
 	for _, action := range resp.BootstrapActions {
 		if action.ScriptPath != nil && strings.HasPrefix(*action.ScriptPath, "s3://") {
-			content, err := downloadS3Script(ctx, s3Client, *action.ScriptPath)
+			content, err := downloader.ReadText(ctx, fmt.Sprintf("EMR cluster %s bootstrap script %s", clusterID, *action.ScriptPath), *action.ScriptPath)
+			if errors.Is(err, ErrTooLarge) {
+				// Recorded by the downloader for the summary
+				content, err = "", nil
+			}
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to download bootstrap script for cluster %s: %w", clusterID, err)
 			}
@@ -154,6 +178,18 @@ func fetchBootstrapActions(ctx context.Context, emrClient *emr.Client, s3Client
 	return resp.BootstrapActions, scriptContents, nil
 }
 
+// fetchSecurityConfiguration returns the JSON document of a security configuration, empty when it cannot be read
+func fetchSecurityConfiguration(ctx context.Context, emrClient *emr.Client, name string) string {
+	output, err := emrClient.DescribeSecurityConfiguration(ctx, &emr.DescribeSecurityConfigurationInput{
+		Name: aws.String(name),
+	})
+	if err != nil {
+		fmt.Printf("Error describing security configuration %s: %v\n", name, err)
+		return ""
+	}
+	return aws.ToString(output.SecurityConfiguration)
+}
+
 func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
 	for _, cluster := range clusters {
 		var hasMatches bool
@@ -162,7 +198,7 @@ func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patte
 		for i, action := range cluster.BootstrapActions {
 			args := strings.Join(action.Args, " ")
 			matches := patternMatcher.MatchPatterns(args, matchMode)
-			if len(matches) > 0 || (i < len(cluster.BootstrapScriptContents) && len(patternMatcher.MatchPatterns(cluster.BootstrapScriptContents[i], matchMode)) > 0) {
+			if len(matches) > 0 || (i < len(cluster.BootstrapScriptContents) && len(matchBootstrapScript(action, cluster.BootstrapScriptContents[i], patternMatcher, matchMode)) > 0) {
 				if !hasMatches {
 					//formatting.EMRClusterID(cluster.ClusterID)
 					formatting.Title("EMR Cluster ID", cluster.ClusterID)
@@ -176,14 +212,15 @@ func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patte
 				//fmt.Printf("    Args: %s\n", args)
 
 				//for pattern, matchPositions := range matches {
-				for pattern, _ := range matches {
+				for _, pattern := range formatting.SortedPatterns(matches) {
 					formatting.PatterName(pattern)
 					//fmt.Printf("    Matched pattern '%s' at positions: %v\n", pattern, matchPositions)
 				}
 				if i < len(cluster.BootstrapScriptContents) {
-					scriptMatches := patternMatcher.MatchPatterns(cluster.BootstrapScriptContents[i], matchMode)
+					scriptMatches := matchBootstrapScript(action, cluster.BootstrapScriptContents[i], patternMatcher, matchMode)
 					//for pattern, matchPositions := range scriptMatches {
-					for patternName, matchedStrings := range scriptMatches {
+					for _, patternName := range formatting.SortedPatterns(scriptMatches) {
+						matchedStrings := scriptMatches[patternName]
 						for _, match := range matchedStrings {
 							formatting.PatterName(patternName)
 							//formatting.EMRScriptPath(*action.ScriptPath)
@@ -215,7 +252,8 @@ func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patte
 				}
 				//formatting.EMRStepName(*step.Name)
 				formatting.Data("EMR Step Name", *step.Name)
-				for patternName, matchedStrings := range matches {
+				for _, patternName := range formatting.SortedPatterns(matches) {
+					matchedStrings := matches[patternName]
 					for _, match := range matchedStrings {
 						formatting.PatterName(patternName)
 						formatting.Content(match, showContent)
@@ -236,7 +274,8 @@ func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patte
 				}
 				//formatting.EMRStepName(fmt.Sprintf("Bootstrap Action %d", i+1))
 				formatting.Data("EMR Step Name", fmt.Sprintf("Bootstrap Action %d", i+1))
-				for patternName, matchedStrings := range matches {
+				for _, patternName := range formatting.SortedPatterns(matches) {
+					matchedStrings := matches[patternName]
 					for _, match := range matchedStrings {
 						formatting.PatterName(patternName)
 						formatting.Content(match, showContent)
@@ -245,8 +284,100 @@ func ProcessEMRClusters(clusters []EMRClusterData, patternMatcher *pattern.Patte
 			}
 		}
 
+		// Process configurations
+		configurationMatches := make(map[string]map[string][]string)
+		matchEMRConfigurations(cluster.Configurations, "", patternMatcher, matchMode, configurationMatches)
+		for _, classification := range sortedClassifications(configurationMatches) {
+			if !hasMatches {
+				formatting.Title("EMR Cluster ID", cluster.ClusterID)
+				hasMatches = true
+			}
+			formatting.Data("Configuration", classification)
+			formatting.FuncCodeDetails(classification, configurationMatches[classification], showContent)
+		}
+
 		if hasMatches {
 			fmt.Println()
 		}
 	}
+
+	processSecurityConfigurations(clusters, patternMatcher, showContent, matchMode)
+}
+
+// matchEMRConfigurations walks the classifications and their nested configurations, scanning each property value with
+// the pattern set, or when nothing matched the property name with the key-name heuristics. Matches are grouped by classification path,
+// e.g. hadoop-env/export, and labelled with the property name
+func matchEMRConfigurations(configurations []types.Configuration, parent string, patternMatcher *pattern.Patterns, matchMode string, matches map[string]map[string][]string) {
+	for _, configuration := range configurations {
+		classification := aws.ToString(configuration.Classification)
+		if parent != "" {
+			classification = parent + "/" + classification
+		}
+		for property, value := range configuration.Properties {
+			found := matchNamedValue(property, value, patternMatcher, matchMode)
+			if len(found) == 0 {
+				continue
+			}
+			if matches[classification] == nil {
+				matches[classification] = make(map[string][]string)
+			}
+			for label, matchedStrings := range found {
+				matches[classification][label] = append(matches[classification][label], matchedStrings...)
+			}
+		}
+		matchEMRConfigurations(configuration.Configurations, classification, patternMatcher, matchMode, matches)
+	}
+}
+
+func sortedClassifications(matches map[string]map[string][]string) []string {
+	classifications := make([]string, 0, len(matches))
+	for classification := range matches {
+		classifications = append(classifications, classification)
+	}
+	sort.Strings(classifications)
+	return classifications
+}
+
+// processSecurityConfigurations reports each security configuration once with the clusters using it, certificates and
+// Kerberos settings are scanned as a JSON document so findings carry their key path
+func processSecurityConfigurations(clusters []EMRClusterData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	var names []string
+	documents := make(map[string]string)
+	usedBy := make(map[string][]string)
+	for _, cluster := range clusters {
+		name := cluster.SecurityConfiguration
+		if name == "" || cluster.SecurityConfigurationJSON == "" {
+			continue
+		}
+		if _, ok := documents[name]; !ok {
+			names = append(names, name)
+			documents[name] = cluster.SecurityConfigurationJSON
+		}
+		usedBy[name] = append(usedBy[name], cluster.ClusterID)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		matches := matchSourceFile(SourceFile{Path: name + ".json", Content: documents[name]}, patternMatcher, matchMode)
+		if len(matches) == 0 {
+			continue
+		}
+		formatting.Title("EMR Security Configuration", name)
+		formatting.Data("Used by", strings.Join(usedBy[name], ", "))
+		formatting.FuncCodeDetails("", matches, showContent)
+		fmt.Println()
+	}
+}
+
+// matchBootstrapScript scans a bootstrap script with the shell detector, or the pattern set and key/value heuristics for other files
+func matchBootstrapScript(action types.Command, content string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	scriptPath := aws.ToString(action.ScriptPath)
+	if pattern.IsShellScript(scriptPath, content) {
+		return patternMatcher.MatchShellScript(content, matchMode)
+	}
+	matches := patternMatcher.MatchPatterns(content, matchMode)
+	for patternName, matchedStrings := range patternMatcher.MatchKeyValues(scriptPath, content) {
+		matches[patternName] = append(matches[patternName], matchedStrings...)
+	}
+	return matches
 }
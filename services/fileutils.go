@@ -0,0 +1,174 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"awsecrets/pattern"
+)
+
+// SourceFile is a single file extracted from a deployment package or downloaded from S3
+type SourceFile struct {
+	Path    string
+	Content string
+}
+
+// FileFilter selects archive entries with include/exclude globs, "**" matches any number of directories
+// and patterns without a "/" are matched against the base name
+type FileFilter struct {
+	Include []string
+	Exclude []string
+}
+
+// binarySniffLength is how much of a file is inspected to decide whether it is binary
+const binarySniffLength = 8000
+
+// ParseGlobs splits a comma separated list of globs
+func ParseGlobs(value string) []string {
+	var globs []string
+	for _, glob := range strings.Split(value, ",") {
+		if glob = strings.TrimSpace(glob); glob != "" {
+			globs = append(globs, glob)
+		}
+	}
+	return globs
+}
+
+// Allows reports whether an entry passes the include and exclude globs
+func (f FileFilter) Allows(name string) bool {
+	name = strings.TrimPrefix(name, "/")
+	if f.Excludes(name) {
+		return false
+	}
+	if len(f.Include) == 0 {
+		return true
+	}
+	for _, glob := range f.Include {
+		if matchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Excludes reports whether an entry matches one of the exclude globs
+func (f FileFilter) Excludes(name string) bool {
+	name = strings.TrimPrefix(name, "/")
+	for _, glob := range f.Exclude {
+		if matchGlob(glob, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchGlob(glob string, name string) bool {
+	if !strings.Contains(glob, "/") {
+		matched, _ := path.Match(glob, path.Base(name))
+		return matched
+	}
+	return globToRegexp(glob).MatchString(name)
+}
+
+func globToRegexp(glob string) *regexp.Regexp {
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					// "**/" matches zero or more directories
+					i++
+					expr.WriteString("(?:.*/)?")
+				} else {
+					expr.WriteString(".*")
+				}
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.MustCompile(expr.String())
+}
+
+// isBinary sniffs the start of a file for NUL bytes or invalid UTF-8
+func isBinary(data []byte) bool {
+	sample := data
+	if len(sample) > binarySniffLength {
+		sample = sample[:binarySniffLength]
+	}
+	if bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	// Allow a rune cut in half at the end of the sample
+	for i := 0; i < utf8.UTFMax && len(sample) > 0 && !utf8.Valid(sample); i++ {
+		sample = sample[:len(sample)-1]
+	}
+	return !utf8.Valid(sample)
+}
+
+// matchSourceFile scans a single file and reports every finding under "<path>:<line>: <pattern>",
+// together with the key/value, literal and shell detectors that apply to the file type
+func matchSourceFile(file SourceFile, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	var found map[string][]string
+	if pattern.IsShellScript(file.Path, file.Content) {
+		found = patternMatcher.MatchShellScript(file.Content, matchMode)
+	} else if pattern.LanguageForFile(file.Path) != "" {
+		found = patternMatcher.MatchPatternsExcept(file.Content, matchMode, pattern.LiteralSupersededRules)
+	} else {
+		found = patternMatcher.MatchPatterns(file.Content, matchMode)
+	}
+	return matchFileFindings(file, found, patternMatcher)
+}
+
+// matchFileFindings labels the pattern findings of a file with their line and adds the key/value and literal detectors
+func matchFileFindings(file SourceFile, found map[string][]string, patternMatcher *pattern.Patterns) map[string][]string {
+	matches := make(map[string][]string)
+	for patternName, matchedStrings := range found {
+		if strings.HasPrefix(patternName, pattern.ShellCredentialRule) {
+			// Shell findings already carry their line
+			key := fmt.Sprintf("%s: %s", file.Path, patternName)
+			matches[key] = append(matches[key], matchedStrings...)
+			continue
+		}
+		offsets := make(map[string]int)
+		for _, match := range matchedStrings {
+			key := fmt.Sprintf("%s:%d: %s", file.Path, lineOf(file.Content, match, offsets), patternName)
+			matches[key] = append(matches[key], match)
+		}
+	}
+	for patternName, matchedStrings := range patternMatcher.MatchKeyValues(file.Path, file.Content) {
+		matches[patternName] = append(matches[patternName], matchedStrings...)
+	}
+	for patternName, matchedStrings := range patternMatcher.MatchSourceLiterals(file.Path, file.Content) {
+		matches[patternName] = append(matches[patternName], matchedStrings...)
+	}
+	return matches
+}
+
+// lineOf returns the line of the next occurrence of match, offsets remembers where the previous one was found
+func lineOf(content string, match string, offsets map[string]int) int {
+	from := offsets[match]
+	index := strings.Index(content[from:], match)
+	if index < 0 {
+		index = strings.Index(content, match)
+		if index < 0 {
+			return 0
+		}
+	} else {
+		index += from
+	}
+	offsets[match] = index + len(match)
+	return strings.Count(content[:index], "\n") + 1
+}
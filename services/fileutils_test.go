@@ -0,0 +1,129 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseGlobs(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"*.py", []string{"*.py"}},
+		{" *.py, ,config/**/*.yml ", []string{"*.py", "config/**/*.yml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := ParseGlobs(tt.value); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseGlobs(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFileFilterAllows(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter FileFilter
+		file   string
+		want   bool
+	}{
+		{"no globs", FileFilter{}, "src/app.py", true},
+		{"base name include", FileFilter{Include: []string{"*.py"}}, "src/app.py", true},
+		{"base name include miss", FileFilter{Include: []string{"*.py"}}, "src/app.js", false},
+		{"exclude wins", FileFilter{Include: []string{"*.py"}, Exclude: []string{"test_*"}}, "tests/test_app.py", false},
+		{"double star directory", FileFilter{Include: []string{"config/**/*.yml"}}, "config/a/b/app.yml", true},
+		{"double star zero directories", FileFilter{Include: []string{"config/**/*.yml"}}, "config/app.yml", true},
+		{"single star stays in directory", FileFilter{Include: []string{"config/*.yml"}}, "config/a/app.yml", false},
+		{"question mark", FileFilter{Include: []string{"src/app?.py"}}, "src/app1.py", true},
+		{"leading slash", FileFilter{Include: []string{"src/*.py"}}, "/src/app.py", true},
+		{"trailing double star", FileFilter{Exclude: []string{"node_modules/**"}}, "node_modules/a/index.js", false},
+		{"leading and trailing double star", FileFilter{Exclude: []string{"**/node_modules/**"}}, "node_modules/a/index.js", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Allows(tt.file); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{"text", []byte("password = x\n"), false},
+		{"utf-8", []byte("pässwort"), false},
+		{"nul byte", []byte("a\x00b"), true},
+		{"invalid utf-8", []byte("a\xffbcdefgh"), true},
+		{"rune cut at the sniff length", []byte(strings.Repeat("a", binarySniffLength-1) + "ä"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinary(tt.data); got != tt.want {
+				t.Errorf("isBinary() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLineOf(t *testing.T) {
+	content := "a\nsecret\nb\nsecret\n"
+	offsets := make(map[string]int)
+	for _, want := range []int{2, 4, 2} {
+		if got := lineOf(content, "secret", offsets); got != want {
+			t.Errorf("lineOf() = %d, want %d", got, want)
+		}
+	}
+	if got := lineOf(content, "missing", offsets); got != 0 {
+		t.Errorf("lineOf() = %d, want 0", got)
+	}
+}
+
+func TestMatchSourceFile(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	tests := []struct {
+		name    string
+		file    SourceFile
+		want    []string
+		notWant []string
+	}{
+		{
+			name:    "python literal supersedes password usage",
+			file:    SourceFile{Path: "app.py", Content: "import os\nDB_PASSWORD = 'Hunter2!'\n"},
+			want:    []string{"Hardcoded Literal (app.py:2, DB_PASSWORD)"},
+			notWant: []string{"app.py:2: Password Usage"},
+		},
+		{
+			name: "plain text keeps password usage",
+			file: SourceFile{Path: "notes.txt", Content: "x\npassword = Hunter2\n"},
+			want: []string{"notes.txt:2: Password Usage"},
+		},
+		{
+			name: "dotenv key values",
+			file: SourceFile{Path: ".env", Content: "STAGE=prod\nDB_PASSWORD=Hunter2\n"},
+			want: []string{"Structured Secret (.env: DB_PASSWORD, line 2)"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches := matchSourceFile(tt.file, patternMatcher, "MatchString")
+			for _, name := range tt.want {
+				if _, ok := matches[name]; !ok {
+					t.Errorf("matchSourceFile() missing %q, got %v", name, matches)
+				}
+			}
+			for _, name := range tt.notWant {
+				if _, ok := matches[name]; ok {
+					t.Errorf("matchSourceFile() reported %q", name)
+				}
+			}
+		})
+	}
+}
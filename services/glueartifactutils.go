@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"path"
+	"strings"
+)
+
+// glueArtifactArguments are the job arguments holding comma separated lists of S3 objects loaded by the job
+var glueArtifactArguments = []string{"--extra-py-files", "--extra-jars", "--extra-files", "--additional-python-modules"}
+
+// GlueJobArtifact ties an artifact to the argument of the job referencing it
+type GlueJobArtifact struct {
+	Argument string
+	Artifact *CachedPackage
+}
+
+// glueJobArtifacts returns the S3 objects listed in the artifact arguments of each argument set, such as the
+// DefaultArguments and NonOverridableArguments of a job. Entries of --additional-python-modules that are package
+// specifiers installed from PyPI are ignored
+func glueJobArtifacts(cache *packageCache, argumentSets ...map[string]string) []GlueJobArtifact {
+	var artifacts []GlueJobArtifact
+	seen := make(map[string]bool)
+	for _, arguments := range argumentSets {
+		for _, argument := range glueArtifactArguments {
+			for _, location := range strings.Split(arguments[argument], ",") {
+				location = strings.TrimSpace(location)
+				if !strings.HasPrefix(location, "s3://") || seen[location] {
+					continue
+				}
+				seen[location] = true
+				artifacts = append(artifacts, GlueJobArtifact{Argument: argument, Artifact: cache.get(location)})
+			}
+		}
+	}
+	return artifacts
+}
+
+// fetchGlueArtifact downloads an artifact and returns its text files, extracting zip, jar, whl, egg and tar.gz archives
+func fetchGlueArtifact(ctx context.Context, downloader *Downloader, source string, location string, filter FileFilter) ([]SourceFile, string) {
+	download, err := downloader.Open(ctx, source, location)
+	if errors.Is(err, ErrTooLarge) {
+		return nil, SkippedTooLarge
+	}
+	if err != nil {
+		log.Printf("Failed to download %s: %v", source, err)
+		return nil, ""
+	}
+	defer download.Close()
+
+	name := path.Base(location)
+	header := make([]byte, 512)
+	n, _ := download.ReadAt(header, 0)
+	if archiveKind(name, header[:n]) != "" {
+		files, err := ExtractArchive(name, download, download.Size, filter, DefaultArchiveLimits)
+		if errors.Is(err, ErrArchiveLimit) {
+			return files, PartiallyScanned + ": " + err.Error()
+		}
+		if err != nil {
+			log.Printf("Failed to extract %s: %v", source, err)
+		}
+		return files, ""
+	}
+
+	if !filter.Allows(name) {
+		return nil, ""
+	}
+	data, err := io.ReadAll(io.NewSectionReader(download, 0, download.Size))
+	if err != nil {
+		log.Printf("Failed to read %s: %v", source, err)
+		return nil, ""
+	}
+	if isBinary(data) {
+		return nil, ""
+	}
+	return []SourceFile{{Path: name, Content: string(data)}}, ""
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGlueJobArtifacts(t *testing.T) {
+	defaultArguments := map[string]string{
+		"--extra-py-files":            "s3://bucket/libs/a.zip, s3://bucket/libs/b.py",
+		"--additional-python-modules": "boto3==1.34.0,s3://bucket/wheels/c.whl",
+		"--TempDir":                   "s3://bucket/tmp/",
+	}
+	nonOverridableArguments := map[string]string{
+		"--extra-jars":  "s3://bucket/jars/d.jar",
+		"--extra-files": "s3://bucket/libs/a.zip",
+	}
+	cache := newPackageCache()
+	var got []string
+	for _, artifact := range glueJobArtifacts(cache, defaultArguments, nonOverridableArguments) {
+		got = append(got, artifact.Argument+" "+artifact.Artifact.Key)
+	}
+	want := []string{
+		"--extra-py-files s3://bucket/libs/a.zip",
+		"--extra-py-files s3://bucket/libs/b.py",
+		"--additional-python-modules s3://bucket/wheels/c.whl",
+		"--extra-jars s3://bucket/jars/d.jar",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("glueJobArtifacts() = %v, want %v", got, want)
+	}
+
+	// Jobs referencing the same object share its artifact
+	shared := glueJobArtifacts(cache, map[string]string{"--extra-files": "s3://bucket/libs/a.zip"})
+	if len(shared) != 1 || shared[0].Artifact != cache.get("s3://bucket/libs/a.zip") {
+		t.Errorf("glueJobArtifacts() = %v, want the cached artifact", shared)
+	}
+	if got := glueJobArtifacts(cache); got != nil {
+		t.Errorf("glueJobArtifacts() = %v, want nil", got)
+	}
+}
+
+func TestFetchGlueArtifact(t *testing.T) {
+	files := map[string][]byte{
+		"/libs.zip":  zipArchive(t, archiveEntry{"pkg/settings.py", []byte("TOKEN = 'abc'")}, archiveEntry{"pkg/cache.pyc", []byte("x")}),
+		"/job.py":    []byte("password = 'Hunter2'"),
+		"/lib.so":    []byte("\x7fELF\x00\x00"),
+		"/cache.pyc": []byte("x = 1"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	filter := FileFilter{Exclude: []string{"*.pyc"}}
+	tests := []struct {
+		name string
+		path string
+		want []SourceFile
+	}{
+		{"archive", "/libs.zip", []SourceFile{{Path: "pkg/settings.py", Content: "TOKEN = 'abc'"}}},
+		{"script", "/job.py", []SourceFile{{Path: "job.py", Content: "password = 'Hunter2'"}}},
+		{"binary", "/lib.so", nil},
+		{"excluded", "/cache.pyc", nil},
+		{"missing", "/missing.py", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := fetchGlueArtifact(context.Background(), NewDownloader(nil, 0, 0), "job", server.URL+tt.path, filter)
+			if status != "" {
+				t.Errorf("fetchGlueArtifact() status = %q, want none", status)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("fetchGlueArtifact() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	if _, status := fetchGlueArtifact(context.Background(), NewDownloader(nil, 4, 0), "job", server.URL+"/job.py", filter); status != SkippedTooLarge {
+		t.Errorf("fetchGlueArtifact() status = %q, want %q", status, SkippedTooLarge)
+	}
+
+	defaultLimits := DefaultArchiveLimits
+	DefaultArchiveLimits = ArchiveLimits{MaxDepth: 3, MaxEntries: 100, MaxTotalSize: 4}
+	defer func() { DefaultArchiveLimits = defaultLimits }()
+	if _, status := fetchGlueArtifact(context.Background(), NewDownloader(nil, 0, 0), "job", server.URL+"/libs.zip", filter); !strings.HasPrefix(status, PartiallyScanned+": ") {
+		t.Errorf("fetchGlueArtifact() status = %q, want %q", status, PartiallyScanned)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+)
+
+type GlueConnectionData struct {
+	Name           string
+	ConnectionType string
+	Properties     map[string]string
+	SecretArn      string
+}
+
+// FetchGlueConnections returns the JDBC, Kafka, MongoDB and other connections with their passwords, which
+// GetConnections only includes when HidePassword is false
+func FetchGlueConnections(ctx context.Context, glueClient *glue.Client) ([]GlueConnectionData, error) {
+	var connections []GlueConnectionData
+	paginator := glue.NewGetConnectionsPaginator(glueClient, &glue.GetConnectionsInput{
+		HidePassword: false,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, connection := range page.ConnectionList {
+			properties := make(map[string]string)
+			for key, value := range connection.ConnectionProperties {
+				properties[key] = value
+			}
+			for key, value := range connection.AthenaProperties {
+				properties[key] = value
+			}
+			data := GlueConnectionData{
+				Name:           aws.ToString(connection.Name),
+				ConnectionType: string(connection.ConnectionType),
+				Properties:     properties,
+			}
+			if connection.AuthenticationConfiguration != nil {
+				data.SecretArn = aws.ToString(connection.AuthenticationConfiguration.SecretArn)
+			}
+			connections = append(connections, data)
+		}
+	}
+	return connections, nil
+}
+
+func ProcessGlueConnections(connections []GlueConnectionData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(connections, func(i, j int) bool {
+		return connections[i].Name < connections[j].Name
+	})
+
+	for _, connection := range connections {
+		matches := matchConnectionProperties(connection, patternMatcher, matchMode)
+		if len(matches) == 0 {
+			continue
+		}
+		formatting.Title("Glue Connection", connection.Name)
+		formatting.Data("Type", connection.ConnectionType)
+		formatting.FuncCodeDetails("", matches, showContent)
+		fmt.Println()
+	}
+}
+
+// matchConnectionProperties flags passwords stored in the connection, noting when no Secrets Manager secret is
+// referenced instead, and scans every other property such as JDBC_CONNECTION_URL with the pattern set
+func matchConnectionProperties(connection GlueConnectionData, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	usesSecret := connection.SecretArn != "" ||
+		connection.Properties["SECRET_ID"] != "" ||
+		connection.Properties["KAFKA_SASL_SCRAM_SECRETS_ARN"] != ""
+
+	matches := make(map[string][]string)
+	for key, value := range connection.Properties {
+		if strings.HasPrefix(key, "ENCRYPTED_") {
+			// KMS ciphertext of the password properties
+			continue
+		}
+		if isConnectionPassword(key) {
+			location := key + ", no SECRET_ID"
+			if usesSecret {
+				location = key + ", alongside a Secrets Manager reference"
+			}
+			for patternName, matchedStrings := range patternMatcher.MatchKeyValue(location, key, value) {
+				matches[patternName] = append(matches[patternName], matchedStrings...)
+			}
+			continue
+		}
+
+		for patternName, matchedStrings := range patternMatcher.MatchPatterns(value, matchMode) {
+			name := fmt.Sprintf("%s: %s", key, patternName)
+			matches[name] = append(matches[name], matchedStrings...)
+		}
+		if key == "SECRET_ID" || key == "KAFKA_SASL_SCRAM_SECRETS_ARN" {
+			// References to Secrets Manager, not secrets
+			continue
+		}
+		for patternName, matchedStrings := range patternMatcher.MatchKeyValue(key, key, value) {
+			matches[patternName] = append(matches[patternName], matchedStrings...)
+		}
+	}
+	return matches
+}
+
+// isConnectionPassword matches PASSWORD and the Kafka password properties
+func isConnectionPassword(key string) bool {
+	return strings.Contains(key, "PASSWORD")
+}
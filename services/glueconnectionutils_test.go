@@ -0,0 +1,56 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMatchConnectionProperties(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	tests := []struct {
+		name       string
+		connection GlueConnectionData
+		want       map[string][]string
+	}{
+		{
+			name: "plaintext passwords without a secret",
+			connection: GlueConnectionData{Properties: map[string]string{
+				"USERNAME":                       "admin",
+				"PASSWORD":                       "Hunter2",
+				"KAFKA_CLIENT_KEYSTORE_PASSWORD": "keystore",
+			}},
+			want: map[string][]string{
+				"Structured Secret (PASSWORD, no SECRET_ID)":                       {"Hunter2"},
+				"Structured Secret (KAFKA_CLIENT_KEYSTORE_PASSWORD, no SECRET_ID)": {"keystore"},
+			},
+		},
+		{
+			name: "password alongside a secret reference",
+			connection: GlueConnectionData{Properties: map[string]string{
+				"PASSWORD":  "Hunter2",
+				"SECRET_ID": "prod/db",
+			}},
+			want: map[string][]string{"Structured Secret (PASSWORD, alongside a Secrets Manager reference)": {"Hunter2"}},
+		},
+		{
+			name: "encrypted passwords are skipped",
+			connection: GlueConnectionData{SecretArn: "arn:aws:secretsmanager:us-east-1:123456789012:secret:db", Properties: map[string]string{
+				"ENCRYPTED_PASSWORD":                  "AQICAHh0ciphertext",
+				"ENCRYPTED_KAFKA_CLIENT_KEY_PASSWORD": "AQICAHh0ciphertext",
+			}},
+			want: map[string][]string{},
+		},
+		{
+			name:       "other properties through the key-name heuristics",
+			connection: GlueConnectionData{Properties: map[string]string{"CONNECTION_URL": "postgres://admin:Hunter2@db/app"}},
+			want:       map[string][]string{"Structured Secret (CONNECTION_URL)": {"postgres://admin:Hunter2@db/app"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchConnectionProperties(tt.connection, patternMatcher, "MatchString"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("matchConnectionProperties() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
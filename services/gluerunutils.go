@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/glue"
+)
+
+// GlueJobRunData holds the run-time Arguments passed when a job was started, which override its DefaultArguments
+type GlueJobRunData struct {
+	JobName   string
+	RunID     string
+	StartedOn time.Time
+	Trigger   string
+	Arguments map[string]string
+}
+
+type GlueTriggerData struct {
+	Name         string
+	Type         string
+	WorkflowName string
+	Actions      []GlueTriggerAction
+}
+
+type GlueTriggerAction struct {
+	Target    string
+	Arguments map[string]string
+}
+
+type GlueWorkflowData struct {
+	Name                 string
+	DefaultRunProperties map[string]string
+	LastRunID            string
+	LastRunProperties    map[string]string
+}
+
+// FetchGlueJobRuns returns the runs of the given jobs started after since that were passed Arguments
+func FetchGlueJobRuns(ctx context.Context, glueClient *glue.Client, jobNames []string, threads int, since time.Time) ([]GlueJobRunData, error) {
+	var runs []GlueJobRunData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	for _, jobName := range jobNames {
+		wg.Add(1)
+		go func(jobName string) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			paginator := glue.NewGetJobRunsPaginator(glueClient, &glue.GetJobRunsInput{
+				JobName: aws.String(jobName),
+			})
+			// Runs are returned newest first
+			for paginator.HasMorePages() {
+				page, err := paginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to get job runs for %s: %v", jobName, err)
+					return
+				}
+				for _, run := range page.JobRuns {
+					startedOn := aws.ToTime(run.StartedOn)
+					if startedOn.Before(since) {
+						return
+					}
+					if len(run.Arguments) == 0 {
+						continue
+					}
+					mu.Lock()
+					runs = append(runs, GlueJobRunData{
+						JobName:   jobName,
+						RunID:     aws.ToString(run.Id),
+						StartedOn: startedOn,
+						Trigger:   aws.ToString(run.TriggerName),
+						Arguments: run.Arguments,
+					})
+					mu.Unlock()
+				}
+			}
+		}(jobName)
+	}
+
+	wg.Wait()
+	return runs, nil
+}
+
+// FetchGlueTriggers returns every trigger with the Arguments of its job actions
+func FetchGlueTriggers(ctx context.Context, glueClient *glue.Client) ([]GlueTriggerData, error) {
+	var triggers []GlueTriggerData
+	paginator := glue.NewGetTriggersPaginator(glueClient, &glue.GetTriggersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, trigger := range page.Triggers {
+			data := GlueTriggerData{
+				Name:         aws.ToString(trigger.Name),
+				Type:         string(trigger.Type),
+				WorkflowName: aws.ToString(trigger.WorkflowName),
+			}
+			for _, action := range trigger.Actions {
+				target := aws.ToString(action.JobName)
+				if target == "" {
+					target = "crawler " + aws.ToString(action.CrawlerName)
+				}
+				data.Actions = append(data.Actions, GlueTriggerAction{Target: target, Arguments: action.Arguments})
+			}
+			triggers = append(triggers, data)
+		}
+	}
+	return triggers, nil
+}
+
+// FetchGlueWorkflows returns the DefaultRunProperties of every workflow and the run properties of its last run
+func FetchGlueWorkflows(ctx context.Context, glueClient *glue.Client, threads int) ([]GlueWorkflowData, error) {
+	var names []string
+	paginator := glue.NewListWorkflowsPaginator(glueClient, &glue.ListWorkflowsInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, page.Workflows...)
+	}
+
+	var workflows []GlueWorkflowData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			output, err := glueClient.GetWorkflow(ctx, &glue.GetWorkflowInput{
+				Name:         aws.String(name),
+				IncludeGraph: aws.Bool(false),
+			})
+			if err != nil {
+				log.Printf("Failed to get workflow %s: %v", name, err)
+				return
+			}
+			data := GlueWorkflowData{
+				Name:                 name,
+				DefaultRunProperties: output.Workflow.DefaultRunProperties,
+			}
+			if lastRun := output.Workflow.LastRun; lastRun != nil {
+				data.LastRunID = aws.ToString(lastRun.WorkflowRunId)
+				data.LastRunProperties = lastRun.WorkflowRunProperties
+			}
+
+			mu.Lock()
+			workflows = append(workflows, data)
+			mu.Unlock()
+		}(name)
+	}
+
+	wg.Wait()
+	return workflows, nil
+}
+
+func ProcessGlueJobRuns(runs []GlueJobRunData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		if runs[i].JobName != runs[j].JobName {
+			return runs[i].JobName < runs[j].JobName
+		}
+		return runs[i].StartedOn.After(runs[j].StartedOn)
+	})
+
+	// Scheduled runs usually repeat the same arguments, each set is reported once under its most recent run
+	type argumentSet struct {
+		run   GlueJobRunData
+		count int
+	}
+	var sets []*argumentSet
+	seen := make(map[string]*argumentSet)
+	for _, run := range runs {
+		key := run.JobName + "\x00" + argumentsKey(run.Arguments)
+		if set, ok := seen[key]; ok {
+			set.count++
+			continue
+		}
+		set := &argumentSet{run: run}
+		seen[key] = set
+		sets = append(sets, set)
+	}
+
+	var printedJob string
+	for _, set := range sets {
+		matches := matchJobParameters(set.run.Arguments, patternMatcher, matchMode)
+		if len(matches) == 0 {
+			continue
+		}
+		if printedJob != set.run.JobName {
+			if printedJob != "" {
+				fmt.Println()
+			}
+			formatting.Title("Glue Job Run", set.run.JobName)
+			printedJob = set.run.JobName
+		}
+		run := fmt.Sprintf("%s (%s)", set.run.RunID, set.run.StartedOn.Format(time.RFC3339))
+		if set.run.Trigger != "" {
+			run += ", trigger " + set.run.Trigger
+		}
+		if set.count > 0 {
+			run += fmt.Sprintf(", same arguments in %d earlier run(s)", set.count)
+		}
+		formatting.Data("Run", run)
+		formatting.FuncCodeDetails("", matches, showContent)
+	}
+	if printedJob != "" {
+		fmt.Println()
+	}
+}
+
+// argumentsKey is a stable representation of a set of arguments
+func argumentsKey(arguments map[string]string) string {
+	keys := make([]string, 0, len(arguments))
+	for key := range arguments {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var builder strings.Builder
+	for _, key := range keys {
+		builder.WriteString(key + "\x00" + arguments[key] + "\x00")
+	}
+	return builder.String()
+}
+
+func ProcessGlueTriggers(triggers []GlueTriggerData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(triggers, func(i, j int) bool {
+		return triggers[i].Name < triggers[j].Name
+	})
+
+	for _, trigger := range triggers {
+		var printed bool
+		for _, action := range trigger.Actions {
+			matches := matchJobParameters(action.Arguments, patternMatcher, matchMode)
+			if len(matches) == 0 {
+				continue
+			}
+			if !printed {
+				formatting.Title("Glue Trigger", trigger.Name)
+				formatting.Data("Type", trigger.Type)
+				if trigger.WorkflowName != "" {
+					formatting.Data("Workflow", trigger.WorkflowName)
+				}
+				printed = true
+			}
+			formatting.Data("Action", action.Target)
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		if printed {
+			fmt.Println()
+		}
+	}
+}
+
+func ProcessGlueWorkflows(workflows []GlueWorkflowData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(workflows, func(i, j int) bool {
+		return workflows[i].Name < workflows[j].Name
+	})
+
+	for _, workflow := range workflows {
+		defaultMatches := matchJobParameters(workflow.DefaultRunProperties, patternMatcher, matchMode)
+
+		// Properties set at run time or by jobs through PutWorkflowRunProperties, those equal to the defaults are reported once
+		lastRunProperties := make(map[string]string)
+		for key, value := range workflow.LastRunProperties {
+			if workflow.DefaultRunProperties[key] != value {
+				lastRunProperties[key] = value
+			}
+		}
+		lastRunMatches := matchJobParameters(lastRunProperties, patternMatcher, matchMode)
+
+		if len(defaultMatches) == 0 && len(lastRunMatches) == 0 {
+			continue
+		}
+		formatting.Title("Glue Workflow", workflow.Name)
+		if len(defaultMatches) > 0 {
+			formatting.Data("Properties", "DefaultRunProperties")
+			formatting.FuncCodeDetails("", defaultMatches, showContent)
+		}
+		if len(lastRunMatches) > 0 {
+			formatting.Data("Properties", "last run "+workflow.LastRunID)
+			formatting.FuncCodeDetails("", lastRunMatches, showContent)
+		}
+		fmt.Println()
+	}
+}
@@ -0,0 +1,103 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+)
+
+// captureOutput returns what report prints to standard output, without colors
+func captureOutput(t *testing.T, report func()) string {
+	t.Helper()
+	out, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	stdout, colorOutput, noColor := os.Stdout, color.Output, color.NoColor
+	os.Stdout, color.Output, color.NoColor = out, out, true
+	report()
+	os.Stdout, color.Output, color.NoColor = stdout, colorOutput, noColor
+
+	data, err := os.ReadFile(out.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestArgumentsKey(t *testing.T) {
+	tests := []struct {
+		name string
+		a    map[string]string
+		b    map[string]string
+		want bool
+	}{
+		{"same arguments", map[string]string{"--a": "1", "--b": "2"}, map[string]string{"--b": "2", "--a": "1"}, true},
+		{"different value", map[string]string{"--a": "1"}, map[string]string{"--a": "2"}, false},
+		{"value shifted into the key", map[string]string{"--a": "1b"}, map[string]string{"--a1": "b"}, false},
+		{"empty", nil, map[string]string{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := argumentsKey(tt.a) == argumentsKey(tt.b); got != tt.want {
+				t.Errorf("argumentsKey(%v) == argumentsKey(%v) is %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessGlueJobRuns(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	started := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	arguments := map[string]string{"--db-password": "Hunter2"}
+	runs := []GlueJobRunData{
+		{JobName: "etl", RunID: "jr_1", StartedOn: started, Arguments: arguments},
+		{JobName: "etl", RunID: "jr_3", StartedOn: started.Add(2 * time.Hour), Trigger: "nightly", Arguments: arguments},
+		{JobName: "etl", RunID: "jr_2", StartedOn: started.Add(time.Hour), Arguments: arguments},
+		{JobName: "etl", RunID: "jr_4", StartedOn: started, Arguments: map[string]string{"--stage": "prod"}},
+	}
+	report := captureOutput(t, func() {
+		ProcessGlueJobRuns(runs, patternMatcher, false, "MatchString")
+	})
+	want := "Run: jr_3 (2024-05-01T02:00:00Z), trigger nightly, same arguments in 2 earlier run(s)\n"
+	if !strings.Contains(report, want) {
+		t.Errorf("ProcessGlueJobRuns() printed %q, want %q", report, want)
+	}
+	for _, runID := range []string{"jr_1", "jr_2", "jr_4"} {
+		if strings.Contains(report, runID) {
+			t.Errorf("ProcessGlueJobRuns() reported %s: %q", runID, report)
+		}
+	}
+	if strings.Count(report, "Glue Job Run: etl") != 1 {
+		t.Errorf("ProcessGlueJobRuns() printed the job title more than once: %q", report)
+	}
+}
+
+func TestProcessGlueWorkflows(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	workflows := []GlueWorkflowData{{
+		Name:                 "nightly",
+		DefaultRunProperties: map[string]string{"db_password": "Hunter2"},
+		LastRunID:            "wr_1",
+		LastRunProperties:    map[string]string{"db_password": "Hunter2", "api_token": "abc123"},
+	}}
+	report := captureOutput(t, func() {
+		ProcessGlueWorkflows(workflows, patternMatcher, false, "MatchString")
+	})
+	for _, want := range []string{
+		"Properties: DefaultRunProperties\nPattern: Structured Secret (db_password)\n",
+		"Properties: last run wr_1\nPattern: Structured Secret (api_token)\n",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("ProcessGlueWorkflows() printed %q, want %q", report, want)
+		}
+	}
+	if strings.Count(report, "db_password") != 1 {
+		t.Errorf("ProcessGlueWorkflows() reported the default db_password again for the last run: %q", report)
+	}
+}
@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"log"
+	"path"
+	"sort"
 	"strings"
 	"sync"
 
@@ -14,18 +16,21 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/glue"
 	glueTypes "github.com/aws/aws-sdk-go-v2/service/glue/types"
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
 type GlueJobData struct {
-	JobName       string
-	Script        string
-	ScriptContent string
-	JobParams     map[string]string
+	JobName              string
+	Script               string
+	ScriptContent        string
+	ScriptStatus         string
+	JobParams            map[string]string
+	NonOverridableParams map[string]string
+	Artifacts            []GlueJobArtifact
 }
 
-func FetchGlueJobs(ctx context.Context, glueClient *glue.Client, s3Client *s3.Client, threads int) ([]GlueJobData, error) {
+func FetchGlueJobs(ctx context.Context, glueClient *glue.Client, downloader *Downloader, threads int, files FileFilter) ([]GlueJobData, error) {
 	var jobs []GlueJobData
+	cache := newPackageCache()
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	errChan := make(chan error, 1)
@@ -52,8 +57,11 @@ func FetchGlueJobs(ctx context.Context, glueClient *glue.Client, s3Client *s3.Cl
 				}
 
 				script := aws.ToString(jobOutput.Job.Command.ScriptLocation)
-				scriptContent, err := downloadS3Script(ctx, s3Client, script)
-				if err != nil {
+				var scriptStatus string
+				scriptContent, err := downloader.ReadText(ctx, "Glue job "+jobName+" script "+script, script)
+				if errors.Is(err, ErrTooLarge) {
+					scriptStatus = SkippedTooLarge
+				} else if err != nil {
 					log.Printf("Failed to download script for job %s: %v", jobName, err)
 					scriptContent = ""
 				}
@@ -62,12 +70,25 @@ func FetchGlueJobs(ctx context.Context, glueClient *glue.Client, s3Client *s3.Cl
 					jobParams[k] = v
 				}
 
+				// Artifacts shared by several jobs are downloaded by the first job to reach them
+				artifacts := glueJobArtifacts(cache, jobOutput.Job.DefaultArguments, jobOutput.Job.NonOverridableArguments)
+				for _, jobArtifact := range artifacts {
+					artifact := jobArtifact.Artifact
+					artifact.once.Do(func() {
+						source := "Glue job " + jobName + " " + jobArtifact.Argument + " " + artifact.Key
+						artifact.Files, artifact.Status = fetchGlueArtifact(ctx, downloader, source, artifact.Key, files)
+					})
+				}
+
 				mu.Lock()
 				jobs = append(jobs, GlueJobData{
-					JobName:       jobName,
-					Script:        script,
-					ScriptContent: scriptContent,
-					JobParams:     jobParams,
+					JobName:              jobName,
+					Script:               script,
+					ScriptContent:        scriptContent,
+					ScriptStatus:         scriptStatus,
+					JobParams:            jobParams,
+					NonOverridableParams: jobOutput.Job.NonOverridableArguments,
+					Artifacts:            artifacts,
 				})
 				mu.Unlock()
 			}
@@ -104,43 +125,52 @@ func FetchGlueJobs(ctx context.Context, glueClient *glue.Client, s3Client *s3.Cl
 	}
 }
 
-func downloadS3Script(ctx context.Context, s3Client *s3.Client, scriptLocation string) (string, error) {
-	parts := strings.SplitN(strings.TrimPrefix(scriptLocation, "s3://"), "/", 2)
-	if len(parts) != 2 {
-		return "", fmt.Errorf("invalid S3 URL: %s", scriptLocation)
-	}
-	bucket := parts[0]
-	key := parts[1]
-
-	result, err := s3Client.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
+func ProcessGlueJobs(jobs []GlueJobData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].JobName < jobs[j].JobName
 	})
-	if err != nil {
-		return "", err
-	}
-	defer result.Body.Close()
 
-	content, err := ioutil.ReadAll(result.Body)
-	if err != nil {
-		return "", err
-	}
-
-	return string(content), nil
-}
-
-func ProcessGlueJobs(jobs []GlueJobData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	// Artifacts shared by several jobs are reported in full under the first one
+	reportedArtifacts := make(map[*CachedPackage]string)
 	for _, job := range jobs {
-		scriptLocationMatches := patternMatcher.MatchPatterns(job.Script, matchMode)
-		scriptContentMatches := patternMatcher.MatchPatterns(job.ScriptContent, matchMode)
+		// The script location is not content, only the script itself is scanned, reported by line
+		var scriptContentMatches map[string][]string
+		if job.ScriptContent != "" {
+			scriptContentMatches = matchSourceFile(SourceFile{Path: path.Base(job.Script), Content: job.ScriptContent}, patternMatcher, matchMode)
+		}
 		paramsMatches := matchJobParameters(job.JobParams, patternMatcher, matchMode)
+		nonOverridableMatches := matchJobParameters(job.NonOverridableParams, patternMatcher, matchMode)
+
+		var artifactMatches []map[string][]string
+		var artifactLabels []string
+		for _, jobArtifact := range job.Artifacts {
+			artifact := jobArtifact.Artifact
+			label := jobArtifact.Argument + " " + artifact.Key
+			matches := artifact.scan(patternMatcher, matchMode)
+			if len(matches) == 0 && artifact.Status == "" {
+				continue
+			}
+			if firstJob, ok := reportedArtifacts[artifact]; ok {
+				artifactMatches = append(artifactMatches, nil)
+				artifactLabels = append(artifactLabels, label+", same artifact as job "+firstJob)
+				continue
+			}
+			reportedArtifacts[artifact] = job.JobName
+			if artifact.Status != "" {
+				label += ": " + artifact.Status
+			}
+			artifactMatches = append(artifactMatches, matches)
+			artifactLabels = append(artifactLabels, label)
+		}
 
-		if len(scriptLocationMatches) > 0 || len(scriptContentMatches) > 0 || len(paramsMatches) > 0 {
+		if len(scriptContentMatches) > 0 || len(paramsMatches) > 0 || len(nonOverridableMatches) > 0 || job.ScriptStatus != "" || len(artifactLabels) > 0 {
 			//formatting.GlueJobName(job.JobName)
 			formatting.Title("Glue Job", job.JobName)
 
-			if len(scriptLocationMatches) > 0 {
-				formatting.FuncCodeDetails("Script Location", scriptLocationMatches, showContent)
+			if job.ScriptStatus != "" {
+				formatting.Data("Script", job.Script+": "+job.ScriptStatus)
+			} else if len(scriptContentMatches) > 0 {
+				formatting.Data("Script", job.Script)
 			}
 
 			if len(scriptContentMatches) > 0 {
@@ -148,21 +178,52 @@ func ProcessGlueJobs(jobs []GlueJobData, patternMatcher *pattern.Patterns, showC
 			}
 
 			if len(paramsMatches) > 0 {
+				formatting.Data("Arguments", "DefaultArguments")
 				formatting.FuncCodeDetails("Job Parameters", paramsMatches, showContent)
 			}
 
+			if len(nonOverridableMatches) > 0 {
+				formatting.Data("Arguments", "NonOverridableArguments")
+				formatting.FuncCodeDetails("Non-overridable Job Parameters", nonOverridableMatches, showContent)
+			}
+
+			for i, matches := range artifactMatches {
+				formatting.Data("Artifact", artifactLabels[i])
+				if len(matches) > 0 {
+					formatting.FuncCodeDetails("", matches, showContent)
+				}
+			}
+
 			fmt.Println()
 		}
 	}
 }
 
+// matchJobParameters scans argument values with the pattern set, or when nothing matched argument names with the
+// key-name heuristics, findings are reported under the argument name
 func matchJobParameters(params map[string]string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
 	matches := make(map[string][]string)
 	for key, value := range params {
-		paramMatches := patternMatcher.MatchPatterns(value, matchMode)
-		for pattern := range paramMatches {
-			matches[pattern] = append(matches[pattern], fmt.Sprintf("%s: %s", key, value))
+		var found map[string][]string
+		if isS3LocationList(value) {
+			// S3 locations such as --TempDir or --extra-py-files only produce noise on bucket names
+			found = patternMatcher.MatchKeyValue(key, key, value)
+		} else {
+			found = matchNamedValue(key, value, patternMatcher, matchMode)
+		}
+		for label, matchedStrings := range found {
+			matches[label] = append(matches[label], matchedStrings...)
 		}
 	}
 	return matches
 }
+
+// isS3LocationList reports whether value is an S3 location or a comma separated list of them
+func isS3LocationList(value string) bool {
+	for _, location := range strings.Split(value, ",") {
+		if !strings.HasPrefix(strings.TrimSpace(location), "s3://") {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"awsecrets/pattern"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamTypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+)
+
+// AccessKeyInfo describes an IAM user access key of the scanned account
+type AccessKeyInfo struct {
+	AccessKeyID     string
+	UserName        string
+	Status          string
+	CreateDate      time.Time
+	LastUsedDate    time.Time
+	LastUsedService string
+}
+
+// FetchAccessKeys indexes every IAM user access key of the account by key ID
+func FetchAccessKeys(ctx context.Context, iamClient *iam.Client, threads int) (map[string]AccessKeyInfo, error) {
+	keys := make(map[string]AccessKeyInfo)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	paginator := iam.NewListUsersPaginator(iamClient, &iam.ListUsersInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list IAM users: %w", err)
+		}
+
+		for _, user := range page.Users {
+			wg.Add(1)
+			go func(user iamTypes.User) {
+				semaphore <- struct{}{}
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				userName := aws.ToString(user.UserName)
+
+				keyPaginator := iam.NewListAccessKeysPaginator(iamClient, &iam.ListAccessKeysInput{
+					UserName: aws.String(userName),
+				})
+				for keyPaginator.HasMorePages() {
+					keyPage, err := keyPaginator.NextPage(ctx)
+					if err != nil {
+						log.Printf("Failed to list access keys for user %s: %v", userName, err)
+						return
+					}
+
+					for _, key := range keyPage.AccessKeyMetadata {
+						info := AccessKeyInfo{
+							AccessKeyID: aws.ToString(key.AccessKeyId),
+							UserName:    userName,
+							Status:      string(key.Status),
+							CreateDate:  aws.ToTime(key.CreateDate),
+						}
+
+						lastUsed, err := iamClient.GetAccessKeyLastUsed(ctx, &iam.GetAccessKeyLastUsedInput{
+							AccessKeyId: key.AccessKeyId,
+						})
+						if err != nil {
+							log.Printf("Failed to get last used date for access key of user %s: %v", userName, err)
+						} else if lastUsed.AccessKeyLastUsed != nil {
+							info.LastUsedDate = aws.ToTime(lastUsed.AccessKeyLastUsed.LastUsedDate)
+							info.LastUsedService = aws.ToString(lastUsed.AccessKeyLastUsed.ServiceName)
+						}
+
+						mu.Lock()
+						keys[info.AccessKeyID] = info
+						mu.Unlock()
+					}
+				}
+			}(user)
+		}
+	}
+
+	wg.Wait()
+	return keys, nil
+}
+
+// IAMAnnotator labels AWS access key matches with the IAM user that owns them, key status and usage dates
+func IAMAnnotator(keys map[string]AccessKeyInfo) pattern.Annotator {
+	return func(match string) []string {
+		var labels []string
+		for _, keyID := range pattern.AccessKeyIDs(match) {
+			if strings.HasPrefix(keyID, "ASIA") {
+				// Temporary credentials do not belong to an IAM user key
+				continue
+			}
+			info, ok := keys[keyID]
+			if !ok {
+				labels = append(labels, "no IAM user key found")
+				continue
+			}
+			labels = append(labels, describeAccessKey(info))
+		}
+		return labels
+	}
+}
+
+func describeAccessKey(info AccessKeyInfo) string {
+	lastUsed := "never used"
+	if !info.LastUsedDate.IsZero() {
+		lastUsed = "last used " + info.LastUsedDate.Format("2006-01-02")
+		if info.LastUsedService != "" && info.LastUsedService != "N/A" {
+			lastUsed += " (" + info.LastUsedService + ")"
+		}
+	}
+	return fmt.Sprintf("IAM user %s, %s, created %s, %s", info.UserName, info.Status, info.CreateDate.Format("2006-01-02"), lastUsed)
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+type LambdaLayerData struct {
+	LayerVersionArn string
+	LayerName       string
+	Version         int64
+	Package         *CachedPackage
+	UsedBy          []string
+}
+
+// FetchLambdaLayers downloads the account's layer versions selected by options.Versions, and every layer version
+// referenced by the scanned functions, scanning each unique package once
+func FetchLambdaLayers(ctx context.Context, lambdaclient *lambda.Client, downloader *Downloader, threads int, options LambdaScanOptions, functions []LambdaFunctionData) ([]LambdaLayerData, error) {
+	// Layer version ARN -> functions using it
+	usedBy := make(map[string][]string)
+	for _, function := range functions {
+		for _, arn := range function.Layers {
+			usedBy[arn] = append(usedBy[arn], function.FunctionName+":"+function.Version)
+		}
+	}
+
+	selected := make(map[string]bool)
+	paginator := lambda.NewListLayersPaginator(lambdaclient, &lambda.ListLayersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, layer := range page.Layers {
+			layerName := aws.ToString(layer.LayerName)
+			var versions []lambdaTypes.LayerVersionsListItem
+			versionPaginator := lambda.NewListLayerVersionsPaginator(lambdaclient, &lambda.ListLayerVersionsInput{
+				LayerName: aws.String(layerName),
+			})
+			for versionPaginator.HasMorePages() {
+				versionPage, err := versionPaginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to list versions for layer %s: %v", layerName, err)
+					break
+				}
+				versions = append(versions, versionPage.LayerVersions...)
+			}
+			for _, version := range selectLayerVersions(versions, options.Versions) {
+				selected[aws.ToString(version.LayerVersionArn)] = true
+			}
+		}
+	}
+	// Layers shared from other accounts are only reachable through the functions using them
+	for arn := range usedBy {
+		selected[arn] = true
+	}
+
+	var layers []LambdaLayerData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+	cache := newPackageCache()
+
+	for arn := range selected {
+		wg.Add(1)
+		go func(arn string) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			output, err := lambdaclient.GetLayerVersionByArn(ctx, &lambda.GetLayerVersionByArnInput{
+				Arn: aws.String(arn),
+			})
+			if err != nil {
+				log.Printf("Failed to get layer version %s: %v", arn, err)
+				return
+			}
+
+			codeSha256 := arn
+			var codeLocation string
+			if output.Content != nil {
+				codeLocation = aws.ToString(output.Content.Location)
+				if sha := aws.ToString(output.Content.CodeSha256); sha != "" {
+					codeSha256 = sha
+				}
+			}
+
+			// Only the first layer version seen with a given CodeSha256 downloads the package
+			pkg := cache.get(codeSha256)
+			pkg.once.Do(func() {
+				pkg.Files, pkg.Status = fetchAndDecodeCode(ctx, downloader, "Lambda layer "+arn, codeLocation, options.Files)
+			})
+
+			users := append([]string(nil), usedBy[arn]...)
+			sort.Strings(users)
+
+			mu.Lock()
+			layers = append(layers, LambdaLayerData{
+				LayerVersionArn: arn,
+				LayerName:       layerNameFromArn(arn),
+				Version:         output.Version,
+				Package:         pkg,
+				UsedBy:          users,
+			})
+			mu.Unlock()
+		}(arn)
+	}
+
+	wg.Wait()
+	return layers, nil
+}
+
+// selectLayerVersions keeps the most recent layer versions, "latest" keeps one and a count N keeps N
+func selectLayerVersions(versions []lambdaTypes.LayerVersionsListItem, limit int) []lambdaTypes.LayerVersionsListItem {
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	if limit == LambdaVersionsLatest {
+		limit = 1
+	}
+	if limit >= 0 && len(versions) > limit {
+		versions = versions[:limit]
+	}
+	return versions
+}
+
+// layerNameFromArn extracts the name from arn:aws:lambda:region:account:layer:name:version
+func layerNameFromArn(arn string) string {
+	parts := strings.Split(arn, ":")
+	if len(parts) >= 8 {
+		return parts[6]
+	}
+	return arn
+}
+
+func ProcessLambdaLayers(layers []LambdaLayerData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	// Newest versions first within each layer
+	sort.SliceStable(layers, func(i, j int) bool {
+		if layers[i].LayerName != layers[j].LayerName {
+			return layers[i].LayerName < layers[j].LayerName
+		}
+		return layers[i].Version > layers[j].Version
+	})
+
+	seenPackages := make(map[*CachedPackage]string)
+	for _, layer := range layers {
+		matches := layer.Package.scan(patternMatcher, matchMode)
+		status := layer.Package.Status
+		if first, ok := seenPackages[layer.Package]; ok && (len(matches) > 0 || status != "") {
+			status = fmt.Sprintf("same package as %s", first)
+			matches = nil
+		} else {
+			seenPackages[layer.Package] = layer.LayerVersionArn
+		}
+		if len(matches) == 0 && status == "" {
+			continue
+		}
+
+		formatting.Title("Lambda Layer", layer.LayerName)
+		formatting.Data("Version", strconv.FormatInt(layer.Version, 10))
+		formatting.Data("ARN", layer.LayerVersionArn)
+		if len(layer.UsedBy) > 0 {
+			formatting.Data("Used by", strings.Join(layer.UsedBy, ", "))
+		} else {
+			formatting.Data("Used by", "no scanned function")
+		}
+		if status != "" {
+			formatting.Data("Code", status)
+		}
+		if len(matches) > 0 {
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		fmt.Println()
+	}
+}
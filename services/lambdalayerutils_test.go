@@ -0,0 +1,50 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestSelectLayerVersions(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  []int64
+	}{
+		{"latest", LambdaVersionsLatest, []int64{7}},
+		{"all", LambdaVersionsAll, []int64{7, 3, 1}},
+		{"count", 2, []int64{7, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versions := []lambdaTypes.LayerVersionsListItem{{Version: 3}, {Version: 7}, {Version: 1}}
+			var got []int64
+			for _, version := range selectLayerVersions(versions, tt.limit) {
+				got = append(got, version.Version)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectLayerVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLayerNameFromArn(t *testing.T) {
+	tests := []struct {
+		arn  string
+		want string
+	}{
+		{"arn:aws:lambda:us-east-1:123456789012:layer:shared-deps:4", "shared-deps"},
+		{"arn:aws:lambda:us-east-1:123456789012:layer:shared-deps", "arn:aws:lambda:us-east-1:123456789012:layer:shared-deps"},
+		{"shared-deps", "shared-deps"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arn, func(t *testing.T) {
+			if got := layerNameFromArn(tt.arn); got != tt.want {
+				t.Errorf("layerNameFromArn(%q) = %q, want %q", tt.arn, got, tt.want)
+			}
+		})
+	}
+}
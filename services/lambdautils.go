@@ -1,15 +1,12 @@
 package services
 
 import (
-	"archive/zip"
 	"awsecrets/formatting"
 	"awsecrets/pattern"
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"sort"
 	"strconv"
 	"sync"
@@ -22,15 +19,46 @@ import (
 type LambdaFunctionData struct {
 	FunctionName string
 	Version      string
-	Code         string
+	Package      *CachedPackage
 	EnvVariables map[string]string
+	Layers       []string
+	ImageCommand []string
 }
 
-func FetchLambdaFunctions(ctx context.Context, lambdaclient *lambda.Client, threads int) ([]LambdaFunctionData, error) {
+// Values of LambdaScanOptions.Versions besides a positive count of published versions
+const (
+	LambdaVersionsLatest = 0
+	LambdaVersionsAll    = -1
+)
+
+// LambdaScanOptions selects which versions and package files are scanned
+type LambdaScanOptions struct {
+	Files    FileFilter
+	Versions int
+	Registry *Registry
+}
+
+// ParseLambdaVersions parses -lambda-versions: "latest", "all" or the number of published versions to scan besides $LATEST
+func ParseLambdaVersions(value string) (int, error) {
+	switch value {
+	case "latest":
+		return LambdaVersionsLatest, nil
+	case "all", "":
+		return LambdaVersionsAll, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("expected latest, all or a number of versions, got %q", value)
+	}
+	return n, nil
+}
+
+func FetchLambdaFunctions(ctx context.Context, lambdaclient *lambda.Client, downloader *Downloader, threads int, options LambdaScanOptions) ([]LambdaFunctionData, error) {
 	var functions []LambdaFunctionData
 	var mu sync.Mutex
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, threads)
+	cache := newPackageCache()
 
 	// Paginator to list all functions
 	paginator := lambda.NewListFunctionsPaginator(lambdaclient, &lambda.ListFunctionsInput{})
@@ -51,7 +79,7 @@ func FetchLambdaFunctions(ctx context.Context, lambdaclient *lambda.Client, thre
 				}()
 				functionName := aws.ToString(function.FunctionName)
 
-				// Collect all versions of the function
+				// Collect all versions of the function, their configuration carries CodeSha256 and the environment
 				var versions []lambdaTypes.FunctionConfiguration
 
 				versionPaginator := lambda.NewListVersionsByFunctionPaginator(lambdaclient, &lambda.ListVersionsByFunctionInput{
@@ -68,39 +96,66 @@ func FetchLambdaFunctions(ctx context.Context, lambdaclient *lambda.Client, thre
 					versions = append(versions, versionPage.Versions...)
 				}
 
-				// Reverse the versions slice to have the latest versions first
-				reverseVersions(versions)
-
-				// Process each version starting from the latest
-				for _, version := range versions {
+				for _, version := range selectVersions(versions, options.Versions) {
 					versionNumber := aws.ToString(version.Version)
 
-					codeOutput, err := lambdaclient.GetFunction(ctx, &lambda.GetFunctionInput{
-						FunctionName: aws.String(functionName),
-						Qualifier:    aws.String(versionNumber),
-					})
-					if err != nil {
-						log.Printf("Failed to get function code for %s version %s: %v", functionName, versionNumber, err)
-						continue
+					// Only the first version seen with a given CodeSha256 downloads the package
+					codeSha256 := aws.ToString(version.CodeSha256)
+					if codeSha256 == "" {
+						codeSha256 = functionName + ":" + versionNumber
 					}
+					pkg := cache.get(codeSha256)
+					pkg.once.Do(func() {
+						codeOutput, err := lambdaclient.GetFunction(ctx, &lambda.GetFunctionInput{
+							FunctionName: aws.String(functionName),
+							Qualifier:    aws.String(versionNumber),
+						})
+						if err != nil {
+							log.Printf("Failed to get function code for %s version %s: %v", functionName, versionNumber, err)
+							return
+						}
+
+						// Container image functions have an ECR image instead of a zip location
+						imageURI := aws.ToString(codeOutput.Code.ResolvedImageUri)
+						if imageURI == "" {
+							imageURI = aws.ToString(codeOutput.Code.ImageUri)
+						}
+						if imageURI != "" {
+							pkg.Files, pkg.Status = fetchImageFiles(ctx, options.Registry, imageURI, imageFilter(options.Files))
+							return
+						}
+
+						codeLocation := aws.ToString(codeOutput.Code.Location)
+						if codeLocation == "" {
+							log.Printf("Empty code location for function %s version %s", functionName, versionNumber)
+						}
+						source := fmt.Sprintf("Lambda function %s:%s", functionName, versionNumber)
+						pkg.Files, pkg.Status = fetchAndDecodeCode(ctx, downloader, source, codeLocation, options.Files)
+					})
 
-					codeLocation := aws.ToString(codeOutput.Code.Location)
-					if codeLocation == "" {
-						log.Printf("Empty code location for function %s version %s", functionName, versionNumber)
+					var envVars map[string]string
+					if version.Environment != nil && version.Environment.Variables != nil {
+						envVars = version.Environment.Variables
 					}
-					code := fetchAndDecodeCode(codeLocation)
 
-					var envVars map[string]string
-					if codeOutput.Configuration.Environment != nil && codeOutput.Configuration.Environment.Variables != nil {
-						envVars = codeOutput.Configuration.Environment.Variables
+					var layers []string
+					for _, layer := range version.Layers {
+						layers = append(layers, aws.ToString(layer.Arn))
+					}
+					var imageCommand []string
+					if version.ImageConfigResponse != nil && version.ImageConfigResponse.ImageConfig != nil {
+						imageConfig := version.ImageConfigResponse.ImageConfig
+						imageCommand = append(append(imageCommand, imageConfig.EntryPoint...), imageConfig.Command...)
 					}
 
 					mu.Lock()
 					functions = append(functions, LambdaFunctionData{
 						FunctionName: functionName,
 						Version:      versionNumber,
-						Code:         code,
+						Package:      pkg,
 						EnvVariables: envVars,
+						Layers:       layers,
+						ImageCommand: imageCommand,
 					})
 					mu.Unlock()
 				}
@@ -112,53 +167,52 @@ func FetchLambdaFunctions(ctx context.Context, lambdaclient *lambda.Client, thre
 	return functions, nil
 }
 
-func fetchAndDecodeCode(codeLocation string) string {
-	if codeLocation == "" {
-		log.Printf("Empty code location provided")
-		return ""
+// selectVersions keeps $LATEST and the most recent published versions, limit is a count, LambdaVersionsLatest or LambdaVersionsAll
+func selectVersions(versions []lambdaTypes.FunctionConfiguration, limit int) []lambdaTypes.FunctionConfiguration {
+	var latest, published []lambdaTypes.FunctionConfiguration
+	for _, version := range versions {
+		if aws.ToString(version.Version) == "$LATEST" {
+			latest = append(latest, version)
+		} else {
+			published = append(published, version)
+		}
 	}
-	resp, err := http.Get(codeLocation)
-	if err != nil {
-		log.Printf("Failed to download Lambda code from %s: %v", codeLocation, err)
-		return ""
+	sort.SliceStable(published, func(i, j int) bool {
+		vi, _ := strconv.Atoi(aws.ToString(published[i].Version))
+		vj, _ := strconv.Atoi(aws.ToString(published[j].Version))
+		return vi > vj
+	})
+	if limit >= 0 && len(published) > limit {
+		published = published[:limit]
 	}
-	defer resp.Body.Close()
+	return append(latest, published...)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Failed to download Lambda code: %s returned status %d", codeLocation, resp.StatusCode)
-		return ""
+// fetchAndDecodeCode downloads a deployment package and returns its text files that pass the filter,
+// the status is set when the package could not be scanned
+func fetchAndDecodeCode(ctx context.Context, downloader *Downloader, source string, codeLocation string, fileFilter FileFilter) ([]SourceFile, string) {
+	if codeLocation == "" {
+		log.Printf("Empty code location provided")
+		return nil, ""
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		log.Printf("Failed to read response body: %v", err)
-		return ""
+	download, err := downloader.Open(ctx, source, codeLocation)
+	if errors.Is(err, ErrTooLarge) {
+		return nil, SkippedTooLarge
 	}
-
-	reader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
 	if err != nil {
-		log.Printf("Failed to unzip Lambda code: %v", err)
-		return ""
+		log.Printf("Failed to download Lambda code for %s: %v", source, err)
+		return nil, ""
 	}
+	defer download.Close()
 
-	var lambdaCode string
-	for _, file := range reader.File {
-		rc, err := file.Open()
-		if err != nil {
-			log.Printf("Failed to open file %s in zip archive: %v", file.Name, err)
-			continue
-		}
-
-		fileContent, err := io.ReadAll(rc)
-		rc.Close()
-		if err != nil {
-			log.Printf("Failed to read file %s content: %v", file.Name, err)
-			continue
-		}
-
-		lambdaCode += string(fileContent) + "\n"
+	files, err := ExtractArchive("", download, download.Size, fileFilter, DefaultArchiveLimits)
+	if errors.Is(err, ErrArchiveLimit) {
+		return files, PartiallyScanned + ": " + err.Error()
+	}
+	if err != nil {
+		log.Printf("Failed to extract Lambda code for %s: %v", source, err)
 	}
-	return lambdaCode
+	return files, ""
 }
 
 func ProcessLambdas(functions []LambdaFunctionData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
@@ -197,23 +251,40 @@ func ProcessLambdas(functions []LambdaFunctionData, patternMatcher *pattern.Patt
 		var hasMatches bool
 		var versionMatches []struct {
 			Version          string
+			Status           string
 			MatchesInCode    map[string][]string
 			MatchesInEnvVars map[string][]string
 		}
 
-		// Process each version
+		// Process each version, versions sharing a package reuse its findings
+		seenPackages := make(map[*CachedPackage]string)
 		for _, function := range versions {
-			matchesInCode := patternMatcher.MatchPatterns(function.Code, matchMode)
+			matchesInCode := function.Package.scan(patternMatcher, matchMode)
+			var status string
+			if function.Package != nil {
+				status = function.Package.Status
+			}
+			if firstVersion, ok := seenPackages[function.Package]; ok && (len(matchesInCode) > 0 || status != "") {
+				status = fmt.Sprintf("same package as version %s", firstVersion)
+				matchesInCode = nil
+			} else {
+				seenPackages[function.Package] = function.Version
+			}
 			matchesInEnvVars := matchEnvVariables(function.EnvVariables, patternMatcher, matchMode)
+			for patternName, matchedStrings := range matchImageCommand(function.ImageCommand, patternMatcher, matchMode) {
+				matchesInEnvVars[patternName] = append(matchesInEnvVars[patternName], matchedStrings...)
+			}
 
-			if len(matchesInCode) > 0 || len(matchesInEnvVars) > 0 {
+			if len(matchesInCode) > 0 || len(matchesInEnvVars) > 0 || status != "" {
 				hasMatches = true
 				versionMatches = append(versionMatches, struct {
 					Version          string
+					Status           string
 					MatchesInCode    map[string][]string
 					MatchesInEnvVars map[string][]string
 				}{
 					Version:          function.Version,
+					Status:           status,
 					MatchesInCode:    matchesInCode,
 					MatchesInEnvVars: matchesInEnvVars,
 				})
@@ -230,6 +301,9 @@ func ProcessLambdas(functions []LambdaFunctionData, patternMatcher *pattern.Patt
 				//fmt.Printf("Version: %s\n", vm.Version)
 				//formatting.LambdaVersion(vm.Version)
 				formatting.Data("Version", vm.Version)
+				if vm.Status != "" {
+					formatting.Data("Code", vm.Status)
+				}
 
 				if len(vm.MatchesInCode) > 0 {
 					formatting.FuncCodeDetails("", vm.MatchesInCode, showContent)
@@ -244,6 +318,19 @@ func ProcessLambdas(functions []LambdaFunctionData, patternMatcher *pattern.Patt
 	}
 }
 
+// matchImageCommand scans the entry point and command a container image function overrides in its ImageConfig
+func matchImageCommand(command []string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	matches := make(map[string][]string)
+	if len(command) == 0 {
+		return matches
+	}
+	for patternName, matchedStrings := range patternMatcher.MatchShellScript(commandScript(command), matchMode) {
+		key := "ImageConfig command: " + patternName
+		matches[key] = append(matches[key], matchedStrings...)
+	}
+	return matches
+}
+
 func matchEnvVariables(envVars map[string]string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
 	matches := make(map[string][]string)
 	for key, value := range envVars {
@@ -267,9 +354,3 @@ func matchEnvVariables(envVars map[string]string, patternMatcher *pattern.Patter
 	}
 	return matches
 }
-
-func reverseVersions(versions []lambdaTypes.FunctionConfiguration) {
-	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
-		versions[i], versions[j] = versions[j], versions[i]
-	}
-}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	lambdaTypes "github.com/aws/aws-sdk-go-v2/service/lambda/types"
+)
+
+func TestParseLambdaVersions(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{"latest", LambdaVersionsLatest, false},
+		{"all", LambdaVersionsAll, false},
+		{"", LambdaVersionsAll, false},
+		{"3", 3, false},
+		{"-1", 0, true},
+		{"newest", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseLambdaVersions(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLambdaVersions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseLambdaVersions() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectVersions(t *testing.T) {
+	versions := []lambdaTypes.FunctionConfiguration{
+		{Version: aws.String("2")},
+		{Version: aws.String("$LATEST")},
+		{Version: aws.String("10")},
+		{Version: aws.String("1")},
+	}
+	tests := []struct {
+		name  string
+		limit int
+		want  []string
+	}{
+		{"latest", LambdaVersionsLatest, []string{"$LATEST"}},
+		{"all", LambdaVersionsAll, []string{"$LATEST", "10", "2", "1"}},
+		{"count", 2, []string{"$LATEST", "10", "2"}},
+		{"count over the number of versions", 10, []string{"$LATEST", "10", "2", "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := append([]lambdaTypes.FunctionConfiguration(nil), versions...)
+			var got []string
+			for _, version := range selectVersions(input, tt.limit) {
+				got = append(got, aws.ToString(version.Version))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("selectVersions() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchAndDecodeCode(t *testing.T) {
+	archive := zipArchive(t, archiveEntry{"a.py", []byte("x = 1")}, archiveEntry{"b.py", []byte("y = 2")})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(archive)
+	}))
+	defer server.Close()
+
+	files, status := fetchAndDecodeCode(context.Background(), NewDownloader(nil, 0, 0), "function", server.URL, FileFilter{})
+	if status != "" || !reflect.DeepEqual(sourcePaths(files), []string{"a.py", "b.py"}) {
+		t.Errorf("fetchAndDecodeCode() = %v, %q, want both files and no status", sourcePaths(files), status)
+	}
+
+	defaultLimits := DefaultArchiveLimits
+	DefaultArchiveLimits = ArchiveLimits{MaxDepth: 3, MaxEntries: 1, MaxTotalSize: 1 << 20}
+	defer func() { DefaultArchiveLimits = defaultLimits }()
+	files, status = fetchAndDecodeCode(context.Background(), NewDownloader(nil, 0, 0), "function", server.URL, FileFilter{})
+	if !strings.HasPrefix(status, PartiallyScanned+": ") || !reflect.DeepEqual(sourcePaths(files), []string{"a.py"}) {
+		t.Errorf("fetchAndDecodeCode() = %v, %q, want the first file and a %q status", sourcePaths(files), status, PartiallyScanned)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"sync"
+
+	"awsecrets/pattern"
+)
+
+// CachedPackage is a set of files downloaded and scanned once, then shared by every resource referencing the same
+// object: Lambda versions and layers with the same CodeSha256, or Glue jobs loading the same S3 artifact
+type CachedPackage struct {
+	Key    string
+	Files  []SourceFile
+	Status string
+
+	once    sync.Once
+	scanned bool
+	matches map[string][]string
+}
+
+// scan matches the package files once, the result is shared by every resource using the package
+func (pkg *CachedPackage) scan(patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	if pkg == nil {
+		return nil
+	}
+	if !pkg.scanned {
+		pkg.matches = make(map[string][]string)
+		for _, file := range pkg.Files {
+			for patternName, matchedStrings := range matchSourceFile(file, patternMatcher, matchMode) {
+				pkg.matches[patternName] = append(pkg.matches[patternName], matchedStrings...)
+			}
+		}
+		pkg.scanned = true
+	}
+	return pkg.matches
+}
+
+// packageCache hands out one CachedPackage per key, such as a CodeSha256 or an S3 location
+type packageCache struct {
+	mu       sync.Mutex
+	packages map[string]*CachedPackage
+}
+
+func newPackageCache() *packageCache {
+	return &packageCache{packages: make(map[string]*CachedPackage)}
+}
+
+func (c *packageCache) get(key string) *CachedPackage {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pkg, ok := c.packages[key]
+	if !ok {
+		pkg = &CachedPackage{Key: key}
+		c.packages[key] = pkg
+	}
+	return pkg
+}
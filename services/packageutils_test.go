@@ -0,0 +1,38 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPackageCache(t *testing.T) {
+	cache := newPackageCache()
+	first := cache.get("sha-a")
+	if cache.get("sha-a") != first {
+		t.Errorf("get() returned a new package for the same key")
+	}
+	if cache.get("sha-b") == first {
+		t.Errorf("get() shared a package across keys")
+	}
+	if first.Key != "sha-a" {
+		t.Errorf("get() Key = %q, want %q", first.Key, "sha-a")
+	}
+}
+
+func TestCachedPackageScan(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	pkg := &CachedPackage{Files: []SourceFile{{Path: "notes.txt", Content: "password = Hunter2"}}}
+	matches := pkg.scan(patternMatcher, "MatchString")
+	if _, ok := matches["notes.txt:1: Password Usage"]; !ok {
+		t.Fatalf("scan() = %v, want the password finding", matches)
+	}
+	// The result is cached, later calls do not rescan the files
+	pkg.Files = nil
+	if got := pkg.scan(patternMatcher, "MatchString"); !reflect.DeepEqual(got, matches) {
+		t.Errorf("scan() = %v, want the cached %v", got, matches)
+	}
+	var missing *CachedPackage
+	if got := missing.scan(patternMatcher, "MatchString"); got != nil {
+		t.Errorf("scan() = %v, want nil", got)
+	}
+}
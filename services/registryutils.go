@@ -0,0 +1,441 @@
+package services
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ecr"
+)
+
+// Manifest media types accepted from the registry
+const (
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// DefaultImageExclude skips operating system and language runtime directories of container images
+var DefaultImageExclude = []string{
+	"usr/**", "lib/**", "lib64/**", "bin/**", "sbin/**", "boot/**", "dev/**", "proc/**", "sys/**",
+	"var/lib/**", "var/cache/**", "var/log/**", "var/lang/**", "var/runtime/**", "etc/ssl/**", "etc/pki/**",
+}
+
+// Descriptor references a blob or manifest by digest
+type Descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+	Platform  *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+// ImageManifest is an image manifest, or an index when Manifests is set
+type ImageManifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    Descriptor   `json:"config"`
+	Layers    []Descriptor `json:"layers"`
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// ImageConfig is the part of the image configuration blob that can carry secrets
+type ImageConfig struct {
+	Config struct {
+		Env        []string          `json:"Env"`
+		Cmd        []string          `json:"Cmd"`
+		Entrypoint []string          `json:"Entrypoint"`
+		Labels     map[string]string `json:"Labels"`
+	} `json:"config"`
+	History []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	} `json:"history"`
+}
+
+// ImageLayer is a layer extracted once per digest and shared by every image containing it
+type ImageLayer struct {
+	Digest string
+	Files  []SourceFile
+	Status string
+
+	once sync.Once
+}
+
+// Registry is a client for the OCI distribution API (registry v2). ECR registries are authenticated with
+// GetAuthorizationToken, when Endpoint is set every image is pulled from it without authentication instead,
+// e.g. a local registry at http://127.0.0.1:5000
+type Registry struct {
+	Endpoint   string
+	Downloader *Downloader
+
+	ecrClient *ecr.Client
+	mu        sync.Mutex
+	token     string
+	layers    map[string]*ImageLayer
+}
+
+// NewRegistry creates a Registry, downloads use the Downloader's timeout and size limit
+func NewRegistry(ecrClient *ecr.Client, endpoint string, downloader *Downloader) *Registry {
+	return &Registry{
+		Endpoint:   strings.TrimSuffix(endpoint, "/"),
+		Downloader: downloader,
+		ecrClient:  ecrClient,
+		layers:     make(map[string]*ImageLayer),
+	}
+}
+
+// ParseImageReference splits host/repository:tag or host/repository@digest
+func ParseImageReference(uri string) (string, string, string, error) {
+	uri = strings.TrimPrefix(strings.TrimPrefix(uri, "https://"), "http://")
+	slash := strings.Index(uri, "/")
+	if slash <= 0 {
+		return "", "", "", fmt.Errorf("invalid image reference: %s", uri)
+	}
+	host, rest := uri[:slash], uri[slash+1:]
+	if at := strings.Index(rest, "@"); at >= 0 {
+		return host, rest[:at], rest[at+1:], nil
+	}
+	if colon := strings.LastIndex(rest, ":"); colon >= 0 {
+		return host, rest[:colon], rest[colon+1:], nil
+	}
+	return host, rest, "latest", nil
+}
+
+func (r *Registry) baseURL(host string) string {
+	if r.Endpoint != "" {
+		return r.Endpoint
+	}
+	return "https://" + host
+}
+
+// authorization returns the Basic credentials of an ECR authorization token, cached for the scan
+func (r *Registry) authorization(ctx context.Context) (string, error) {
+	if r.Endpoint != "" || r.ecrClient == nil {
+		return "", nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.token != "" {
+		return r.token, nil
+	}
+	output, err := r.ecrClient.GetAuthorizationToken(ctx, &ecr.GetAuthorizationTokenInput{})
+	if err != nil {
+		return "", err
+	}
+	if len(output.AuthorizationData) == 0 {
+		return "", errors.New("no ECR authorization data returned")
+	}
+	r.token = "Basic " + aws.ToString(output.AuthorizationData[0].AuthorizationToken)
+	return r.token, nil
+}
+
+func (r *Registry) get(ctx context.Context, host string, path string, accept ...string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.baseURL(host)+"/v2/"+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := r.authorization(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get registry authorization: %w", err)
+	}
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+	resp, err := r.Downloader.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned status %d for %s", resp.StatusCode, path)
+	}
+	return resp, nil
+}
+
+func (r *Registry) getJSON(ctx context.Context, host string, path string, v interface{}, accept ...string) ([]byte, error) {
+	resp, err := r.get(ctx, host, path, accept...)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	// Manifests and configs are small, the cap only guards against a misbehaving registry
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, err
+	}
+	return body, json.Unmarshal(body, v)
+}
+
+// Manifest fetches an image manifest, resolving an index to its linux/amd64 image, and returns it with its digest
+func (r *Registry) Manifest(ctx context.Context, host string, repository string, reference string) (*ImageManifest, string, error) {
+	var manifest ImageManifest
+	body, err := r.getJSON(ctx, host, repository+"/manifests/"+reference, &manifest,
+		mediaTypeOCIManifest, mediaTypeDockerManifest, mediaTypeOCIIndex, mediaTypeDockerList)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(manifest.Manifests) > 0 {
+		if image := pickPlatform(manifest.Manifests); image != "" {
+			return r.Manifest(ctx, host, repository, image)
+		}
+		return nil, "", fmt.Errorf("no image manifest in index %s:%s", repository, reference)
+	}
+	digest := reference
+	if !strings.HasPrefix(digest, "sha256:") {
+		sum := sha256.Sum256(body)
+		digest = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return &manifest, digest, nil
+}
+
+// pickPlatform prefers linux/amd64 and skips attestation manifests
+func pickPlatform(manifests []Descriptor) string {
+	var fallback string
+	for _, m := range manifests {
+		if m.Platform == nil {
+			if fallback == "" {
+				fallback = m.Digest
+			}
+			continue
+		}
+		if m.Platform.OS == "linux" && m.Platform.Architecture == "amd64" {
+			return m.Digest
+		}
+		if fallback == "" && m.Platform.OS != "unknown" {
+			fallback = m.Digest
+		}
+	}
+	return fallback
+}
+
+// Config fetches the image configuration blob
+func (r *Registry) Config(ctx context.Context, host string, repository string, manifest *ImageManifest) (*ImageConfig, error) {
+	var config ImageConfig
+	if _, err := r.getJSON(ctx, host, repository+"/blobs/"+manifest.Config.Digest, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// Tags lists the tags of a repository
+func (r *Registry) Tags(ctx context.Context, host string, repository string) ([]string, error) {
+	var list struct {
+		Tags []string `json:"tags"`
+	}
+	_, err := r.getJSON(ctx, host, repository+"/tags/list", &list)
+	return list.Tags, err
+}
+
+// Catalog lists the repositories of a registry, used when Endpoint replaces ECR
+func (r *Registry) Catalog(ctx context.Context, host string) ([]string, error) {
+	var catalog struct {
+		Repositories []string `json:"repositories"`
+	}
+	_, err := r.getJSON(ctx, host, "_catalog", &catalog)
+	return catalog.Repositories, err
+}
+
+// Layer extracts a layer once per digest, layers over the download limit are recorded as skipped
+func (r *Registry) Layer(ctx context.Context, host string, repository string, layer Descriptor, filter FileFilter) (*ImageLayer, bool) {
+	r.mu.Lock()
+	cached, seen := r.layers[layer.Digest]
+	if !seen {
+		cached = &ImageLayer{Digest: layer.Digest}
+		r.layers[layer.Digest] = cached
+	}
+	r.mu.Unlock()
+
+	cached.once.Do(func() {
+		source := fmt.Sprintf("image layer %s/%s@%s", host, repository, layer.Digest)
+		if layer.Size > r.Downloader.MaxSize {
+			r.Downloader.skip(source, layer.Size)
+			cached.Status = SkippedTooLarge
+			return
+		}
+		if strings.Contains(layer.MediaType, "zstd") {
+			cached.Status = "skipped: zstd compressed layer"
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, r.Downloader.Timeout)
+		defer cancel()
+		resp, err := r.get(ctx, host, repository+"/blobs/"+layer.Digest)
+		if err != nil {
+			cached.Status = fmt.Sprintf("failed: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+
+		var reader io.Reader = io.LimitReader(resp.Body, r.Downloader.MaxSize)
+		if strings.HasSuffix(layer.MediaType, "gzip") {
+			gz, err := gzip.NewReader(reader)
+			if err != nil {
+				cached.Status = fmt.Sprintf("failed: %v", err)
+				return
+			}
+			defer gz.Close()
+			reader = gz
+		}
+		files, err := ExtractTar(reader, filter, DefaultArchiveLimits)
+		cached.Files = files
+		if errors.Is(err, ErrArchiveLimit) {
+			cached.Status = PartiallyScanned + ": " + err.Error()
+		} else if err != nil {
+			cached.Status = fmt.Sprintf("failed: %v", err)
+		}
+	})
+	return cached, seen
+}
+
+// imageFilter adds DefaultImageExclude to the user's filter
+func imageFilter(filter FileFilter) FileFilter {
+	return FileFilter{
+		Include: filter.Include,
+		Exclude: append(append([]string(nil), filter.Exclude...), DefaultImageExclude...),
+	}
+}
+
+// imageConfigFiles turns the image configuration into files the scanners understand: the environment and the labels
+// as .env files and the entrypoint, command and build history as a shell script
+func imageConfigFiles(config *ImageConfig) []SourceFile {
+	var files []SourceFile
+	if len(config.Config.Env) > 0 {
+		files = append(files, SourceFile{Path: "image config/.env", Content: strings.Join(config.Config.Env, "\n")})
+	}
+	if len(config.Config.Labels) > 0 {
+		labels := make([]string, 0, len(config.Config.Labels))
+		for name, value := range config.Config.Labels {
+			labels = append(labels, name+"="+strings.Join(strings.Fields(value), " "))
+		}
+		sort.Strings(labels)
+		files = append(files, SourceFile{Path: "image config/labels.env", Content: strings.Join(labels, "\n")})
+	}
+
+	var script []string
+	if command := append(append([]string(nil), config.Config.Entrypoint...), config.Config.Cmd...); len(command) > 0 {
+		script = append(script, commandScript(command))
+	}
+	for _, history := range config.History {
+		if line := historyCommand(history.CreatedBy); line != "" {
+			script = append(script, line)
+		}
+	}
+	if len(script) > 0 {
+		files = append(files, SourceFile{Path: "image config/commands.sh", Content: strings.Join(script, "\n")})
+	}
+	return files
+}
+
+// historyCommand turns a history created_by entry into a shell line: RUN commands are kept, ENV and ARG become exports
+// and the other Dockerfile instructions are dropped. Entries look like "/bin/sh -c #(nop)  ENV A=b",
+// "RUN /bin/sh -c apt-get ... # buildkit" or "|1 TOKEN=x /bin/sh -c ..." for RUN with build args
+func historyCommand(createdBy string) string {
+	line := strings.TrimSpace(createdBy)
+	line = strings.TrimPrefix(line, "RUN ")
+	if strings.HasPrefix(line, "|") {
+		if space := strings.Index(line, " "); space > 0 {
+			line = line[space+1:]
+		}
+	}
+	line = strings.Replace(line, "/bin/sh -c ", "", 1)
+	line = strings.TrimSuffix(line, " # buildkit")
+	line = strings.TrimSpace(strings.TrimPrefix(line, "#(nop)"))
+
+	instruction, rest, _ := strings.Cut(line, " ")
+	switch instruction {
+	case "ENV", "ARG":
+		return "export " + strings.TrimSpace(rest)
+	case "COPY", "ADD", "LABEL", "WORKDIR", "CMD", "ENTRYPOINT", "EXPOSE", "USER", "VOLUME", "STOPSIGNAL",
+		"HEALTHCHECK", "SHELL", "ONBUILD", "MAINTAINER":
+		return ""
+	}
+	return line
+}
+
+// commandScript turns an exec form command into a script, unwrapping ["sh", "-c", "script"] so the inner script is tokenised
+func commandScript(args []string) string {
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-c" && i > 0 && isShellProgram(args[i-1]) {
+			return args[i+1]
+		}
+	}
+	return shellJoin(args)
+}
+
+// isShellProgram reports whether a command runs a shell, e.g. /bin/sh or bash
+func isShellProgram(program string) bool {
+	switch path.Base(program) {
+	case "sh", "bash", "ash", "dash", "zsh", "ksh":
+		return true
+	}
+	return false
+}
+
+// shellJoin quotes arguments so the shell tokenizer reads them back unchanged
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return strings.Join(quoted, " ")
+}
+
+// fetchImageFiles resolves an image and returns its configuration files and the files of its layers,
+// the status describes layers that could not be scanned
+func fetchImageFiles(ctx context.Context, registry *Registry, imageURI string, filter FileFilter) ([]SourceFile, string) {
+	if registry == nil {
+		return nil, "skipped: container image scanning disabled"
+	}
+	host, repository, reference, err := ParseImageReference(imageURI)
+	if err != nil {
+		return nil, fmt.Sprintf("failed: %v", err)
+	}
+	manifest, _, err := registry.Manifest(ctx, host, repository, reference)
+	if err != nil {
+		return nil, fmt.Sprintf("failed to fetch image manifest: %v", err)
+	}
+
+	var files []SourceFile
+	if config, err := registry.Config(ctx, host, repository, manifest); err != nil {
+		log.Printf("Failed to fetch image config for %s: %v", imageURI, err)
+	} else {
+		files = append(files, imageConfigFiles(config)...)
+	}
+
+	var statuses []string
+	for _, descriptor := range manifest.Layers {
+		layer, _ := registry.Layer(ctx, host, repository, descriptor, filter)
+		files = append(files, layer.Files...)
+		if layer.Status != "" {
+			statuses = append(statuses, fmt.Sprintf("layer %s %s", shortDigest(layer.Digest), layer.Status))
+		}
+	}
+	return files, strings.Join(statuses, "; ")
+}
+
+// shortDigest abbreviates sha256:<64 hex> to its first 12 hex characters
+func shortDigest(digest string) string {
+	hexDigest := strings.TrimPrefix(digest, "sha256:")
+	if len(hexDigest) > 12 {
+		return hexDigest[:12]
+	}
+	return hexDigest
+}
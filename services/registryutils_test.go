@@ -0,0 +1,266 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"awsecrets/pattern"
+)
+
+func TestParseImageReference(t *testing.T) {
+	tests := []struct {
+		uri            string
+		wantHost       string
+		wantRepository string
+		wantReference  string
+		wantErr        bool
+	}{
+		{"123456789012.dkr.ecr.us-east-1.amazonaws.com/app:v1", "123456789012.dkr.ecr.us-east-1.amazonaws.com", "app", "v1", false},
+		{"registry:5000/team/app@sha256:abc", "registry:5000", "team/app", "sha256:abc", false},
+		{"https://registry/app", "registry", "app", "latest", false},
+		{"app:v1", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			host, repository, reference, err := ParseImageReference(tt.uri)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseImageReference() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if host != tt.wantHost || repository != tt.wantRepository || reference != tt.wantReference {
+				t.Errorf("ParseImageReference() = %q, %q, %q, want %q, %q, %q",
+					host, repository, reference, tt.wantHost, tt.wantRepository, tt.wantReference)
+			}
+		})
+	}
+}
+
+func platformDescriptor(digest string, os string, architecture string) Descriptor {
+	d := Descriptor{Digest: digest}
+	if os != "" {
+		d.Platform = &struct {
+			Architecture string `json:"architecture"`
+			OS           string `json:"os"`
+		}{Architecture: architecture, OS: os}
+	}
+	return d
+}
+
+func TestPickPlatform(t *testing.T) {
+	tests := []struct {
+		name      string
+		manifests []Descriptor
+		want      string
+	}{
+		{
+			name:      "linux/amd64 preferred",
+			manifests: []Descriptor{platformDescriptor("arm", "linux", "arm64"), platformDescriptor("amd", "linux", "amd64")},
+			want:      "amd",
+		},
+		{
+			name:      "attestations skipped",
+			manifests: []Descriptor{platformDescriptor("att", "unknown", "unknown"), platformDescriptor("arm", "linux", "arm64")},
+			want:      "arm",
+		},
+		{
+			name:      "no platform",
+			manifests: []Descriptor{platformDescriptor("plain", "", "")},
+			want:      "plain",
+		},
+		{name: "empty", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pickPlatform(tt.manifests); got != tt.want {
+				t.Errorf("pickPlatform() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryCommand(t *testing.T) {
+	tests := []struct {
+		createdBy string
+		want      string
+	}{
+		{"/bin/sh -c #(nop)  ENV DB_PASSWORD=Hunter2", "export DB_PASSWORD=Hunter2"},
+		{"ARG TOKEN=abc", "export TOKEN=abc"},
+		{"RUN /bin/sh -c curl -u admin:Hunter2 https://x # buildkit", "curl -u admin:Hunter2 https://x"},
+		{"|1 TOKEN=abc /bin/sh -c pip install app", "TOKEN=abc pip install app"},
+		{"/bin/sh -c #(nop) COPY file:abc in /app", ""},
+		{"/bin/sh -c #(nop)  CMD [\"python\"]", ""},
+		{"WORKDIR /app", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.createdBy, func(t *testing.T) {
+			if got := historyCommand(tt.createdBy); got != tt.want {
+				t.Errorf("historyCommand(%q) = %q, want %q", tt.createdBy, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandScript(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"sh -c", []string{"/bin/sh", "-c", "export TOKEN=abc && ./run"}, "export TOKEN=abc && ./run"},
+		{"bash -c after options", []string{"bash", "-e", "-c", "./run"}, "'bash' '-e' '-c' './run'"},
+		{"exec form", []string{"python", "app.py", "--password", "it's"}, `'python' 'app.py' '--password' 'it'\''s'`},
+		{"-c of another program", []string{"python", "-c", "print()"}, "'python' '-c' 'print()'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := commandScript(tt.args); got != tt.want {
+				t.Errorf("commandScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShellJoinRoundTrip(t *testing.T) {
+	args := []string{"run", "a b", "it's", `"quoted"`, "$HOME", ""}
+	commands := pattern.TokenizeShell(shellJoin(args))
+	if len(commands) != 1 || !reflect.DeepEqual(commands[0].Args, args) {
+		t.Errorf("TokenizeShell(shellJoin()) = %#v, want %q", commands, args)
+	}
+}
+
+func TestImageConfigFiles(t *testing.T) {
+	var config ImageConfig
+	config.Config.Env = []string{"PATH=/usr/bin", "DB_PASSWORD=Hunter2"}
+	config.Config.Entrypoint = []string{"/bin/sh", "-c"}
+	config.Config.Cmd = []string{"./run --token abc"}
+	config.Config.Labels = map[string]string{"org.opencontainers.image.version": "1.2", "db.password": "Hunter2\nagain"}
+	config.History = []struct {
+		CreatedBy  string `json:"created_by"`
+		EmptyLayer bool   `json:"empty_layer"`
+	}{
+		{CreatedBy: "/bin/sh -c #(nop)  ENV STAGE=prod"},
+		{CreatedBy: "/bin/sh -c #(nop) WORKDIR /app"},
+	}
+	want := []SourceFile{
+		{Path: "image config/.env", Content: "PATH=/usr/bin\nDB_PASSWORD=Hunter2"},
+		{Path: "image config/labels.env", Content: "db.password=Hunter2 again\norg.opencontainers.image.version=1.2"},
+		{Path: "image config/commands.sh", Content: "./run --token abc\nexport STAGE=prod"},
+	}
+	if got := imageConfigFiles(&config); !reflect.DeepEqual(got, want) {
+		t.Errorf("imageConfigFiles() = %#v, want %#v", got, want)
+	}
+	labels := want[1]
+	if matches := loadTestPatterns(t).MatchKeyValues(labels.Path, labels.Content); len(matches["Structured Secret (image config/labels.env: db.password, line 1)"]) != 1 {
+		t.Errorf("MatchKeyValues() = %v, want the db.password label", matches)
+	}
+	if got := imageConfigFiles(&ImageConfig{}); got != nil {
+		t.Errorf("imageConfigFiles() = %#v, want nil", got)
+	}
+}
+
+func TestShortDigest(t *testing.T) {
+	tests := []struct {
+		digest string
+		want   string
+	}{
+		{"sha256:0123456789abcdef0123", "0123456789ab"},
+		{"sha256:abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.digest, func(t *testing.T) {
+			if got := shortDigest(tt.digest); got != tt.want {
+				t.Errorf("shortDigest(%q) = %q, want %q", tt.digest, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImageFilter(t *testing.T) {
+	filter := FileFilter{Include: []string{"*.py"}, Exclude: []string{"*.pyc"}}
+	got := imageFilter(filter)
+	if !got.Allows("app/main.py") || got.Allows("usr/lib/python3/os.py") || got.Allows("app/main.pyc") {
+		t.Errorf("imageFilter() = %v, want the user globs and DefaultImageExclude", got)
+	}
+	if len(filter.Exclude) != 1 {
+		t.Errorf("imageFilter() modified the user's filter: %v", filter.Exclude)
+	}
+}
+
+func TestFetchImageFiles(t *testing.T) {
+	layer := tarGzArchive(t,
+		archiveEntry{"app/settings.py", []byte("DB_PASSWORD = 'Hunter2!'")},
+		archiveEntry{"usr/lib/os.py", []byte("x = 1")},
+	)
+	blobs := map[string]interface{}{
+		"index": ImageManifest{
+			MediaType: mediaTypeOCIIndex,
+			Manifests: []Descriptor{platformDescriptor("sha256:image", "linux", "amd64")},
+		},
+		"sha256:image": ImageManifest{
+			MediaType: mediaTypeOCIManifest,
+			Config:    Descriptor{Digest: "sha256:config"},
+			Layers: []Descriptor{
+				{MediaType: "application/vnd.oci.image.layer.v1.tar+gzip", Digest: "sha256:layer", Size: int64(len(layer))},
+				{MediaType: "application/vnd.oci.image.layer.v1.tar+zstd", Digest: "sha256:zstdlayer0123456789"},
+			},
+		},
+	}
+	var config ImageConfig
+	config.Config.Env = []string{"API_TOKEN=abc"}
+	blobs["sha256:config"] = config
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		if name == "sha256:layer" {
+			w.Write(layer)
+			return
+		}
+		blob, ok := blobs[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(blob)
+	}))
+	defer server.Close()
+
+	registry := NewRegistry(nil, server.URL+"/", NewDownloader(nil, 0, 0))
+	files, status := fetchImageFiles(context.Background(), registry, "registry/app:index", imageFilter(FileFilter{}))
+	wantFiles := []SourceFile{
+		{Path: "image config/.env", Content: "API_TOKEN=abc"},
+		{Path: "app/settings.py", Content: "DB_PASSWORD = 'Hunter2!'"},
+	}
+	if !reflect.DeepEqual(files, wantFiles) {
+		t.Errorf("fetchImageFiles() files = %#v, want %#v", files, wantFiles)
+	}
+	if want := "layer zstdlayer012 skipped: zstd compressed layer"; status != want {
+		t.Errorf("fetchImageFiles() status = %q, want %q", status, want)
+	}
+
+	// Layers are extracted once per digest
+	if _, seen := registry.Layer(context.Background(), "registry", "other", Descriptor{Digest: "sha256:layer"}, FileFilter{}); !seen {
+		t.Errorf("Layer() seen = false, want the cached layer")
+	}
+
+	if _, status := fetchImageFiles(context.Background(), nil, "registry/app:v1", FileFilter{}); status == "" {
+		t.Errorf("fetchImageFiles() status = %q, want a skipped status without a registry", status)
+	}
+	if _, status := fetchImageFiles(context.Background(), registry, "registry/missing:v1", FileFilter{}); !strings.HasPrefix(status, "failed to fetch image manifest") {
+		t.Errorf("fetchImageFiles() status = %q, want a manifest error", status)
+	}
+}
+
+func TestMatchImageCommand(t *testing.T) {
+	patternMatcher := loadTestPatterns(t)
+	matches := matchImageCommand([]string{"sh", "-c", "export DB_PASSWORD=Hunter2 && ./run"}, patternMatcher, "MatchString")
+	if _, ok := matches["ImageConfig command: Shell Credential (export DB_PASSWORD arg 1, line 1)"]; !ok {
+		t.Errorf("matchImageCommand() = %v, want the exported password", matches)
+	}
+	if got := matchImageCommand(nil, patternMatcher, "MatchString"); len(got) != 0 {
+		t.Errorf("matchImageCommand() = %v, want no matches", got)
+	}
+}
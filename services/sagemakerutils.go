@@ -108,7 +108,8 @@ func ProcessSageMakerJobs(jobs []SageMakerProcessingJobData, patternMatcher *pat
 		matches := patternMatcher.MatchPatterns(job.Description, matchMode)
 		if len(matches) > 0 {
 			formatting.Title("SageMaker job", job.Name)
-			for pattern, matchList := range matches {
+			for _, pattern := range formatting.SortedPatterns(matches) {
+				matchList := matches[pattern]
 				for _, match := range matchList {
 					formatting.PatterName(pattern)
 					formatting.Content(match, showContent)
@@ -119,10 +120,11 @@ func ProcessSageMakerJobs(jobs []SageMakerProcessingJobData, patternMatcher *pat
 		envMatches := matchEnvVariables(job.Environment, patternMatcher, matchMode)
 		if len(envMatches) > 0 {
 			//fmt.Printf("  Found matches in environment variables:\n")
-			for envVar, matchList := range envMatches {
+			for _, envVar := range formatting.SortedPatterns(envMatches) {
+				matchList := envMatches[envVar]
 				for _, match := range matchList {
 					formatting.Title("SageMaker job", job.Name)
-					formatting.Data("SageMaker environment", envVar)
+					formatting.PatterName(envVar)
 					formatting.Content(match, showContent)
 				}
 			}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"gopkg.in/yaml.v3"
+)
+
+type SSMDocumentData struct {
+	Name         string
+	DocumentType string
+	Versions     []SSMDocumentVersion
+}
+
+type SSMDocumentVersion struct {
+	Version   string
+	IsDefault bool
+	Format    string
+	Content   string
+}
+
+// SSMCommandData is a Run Command invocation with its parameters and the output of each instance
+type SSMCommandData struct {
+	CommandID       string
+	DocumentName    string
+	DocumentVersion string
+	RequestedAt     time.Time
+	Parameters      map[string][]string
+	Invocations     []SSMCommandInvocation
+}
+
+// SSMCommandInvocation holds the output of every plugin run on an instance, truncated by SSM to 2500 characters
+type SSMCommandInvocation struct {
+	InstanceID string
+	Outputs    map[string]string
+}
+
+// FetchSSMDocuments returns every version of the Command, Automation and other documents owned by the account
+func FetchSSMDocuments(ctx context.Context, ssmClient *ssm.Client, threads int) ([]SSMDocumentData, error) {
+	var identifiers []ssmTypes.DocumentIdentifier
+	paginator := ssm.NewListDocumentsPaginator(ssmClient, &ssm.ListDocumentsInput{
+		Filters: []ssmTypes.DocumentKeyValuesFilter{
+			{Key: aws.String("Owner"), Values: []string{"Self"}},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		identifiers = append(identifiers, page.DocumentIdentifiers...)
+	}
+
+	var documents []SSMDocumentData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	for _, identifier := range identifiers {
+		wg.Add(1)
+		go func(identifier ssmTypes.DocumentIdentifier) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			name := aws.ToString(identifier.Name)
+			document := SSMDocumentData{Name: name, DocumentType: string(identifier.DocumentType)}
+			versionPaginator := ssm.NewListDocumentVersionsPaginator(ssmClient, &ssm.ListDocumentVersionsInput{
+				Name: aws.String(name),
+			})
+			for versionPaginator.HasMorePages() {
+				page, err := versionPaginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to list versions for document %s: %v", name, err)
+					break
+				}
+				for _, version := range page.DocumentVersions {
+					output, err := ssmClient.GetDocument(ctx, &ssm.GetDocumentInput{
+						Name:            aws.String(name),
+						DocumentVersion: version.DocumentVersion,
+					})
+					if err != nil {
+						log.Printf("Failed to get document %s version %s: %v", name, aws.ToString(version.DocumentVersion), err)
+						continue
+					}
+					document.Versions = append(document.Versions, SSMDocumentVersion{
+						Version:   aws.ToString(version.DocumentVersion),
+						IsDefault: version.IsDefaultVersion,
+						Format:    string(output.DocumentFormat),
+						Content:   aws.ToString(output.Content),
+					})
+				}
+			}
+
+			sort.SliceStable(document.Versions, func(i, j int) bool {
+				left, _ := strconv.Atoi(document.Versions[i].Version)
+				right, _ := strconv.Atoi(document.Versions[j].Version)
+				return left > right
+			})
+
+			mu.Lock()
+			documents = append(documents, document)
+			mu.Unlock()
+		}(identifier)
+	}
+
+	wg.Wait()
+	return documents, nil
+}
+
+// FetchSSMCommands returns the Run Command invocations requested after since, with the output of each instance
+func FetchSSMCommands(ctx context.Context, ssmClient *ssm.Client, threads int, since time.Time) ([]SSMCommandData, error) {
+	var commands []ssmTypes.Command
+	paginator := ssm.NewListCommandsPaginator(ssmClient, &ssm.ListCommandsInput{
+		Filters: []ssmTypes.CommandFilter{
+			{Key: ssmTypes.CommandFilterKeyInvokedAfter, Value: aws.String(since.UTC().Format(time.RFC3339))},
+		},
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, page.Commands...)
+	}
+
+	var result []SSMCommandData
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, threads)
+
+	for _, command := range commands {
+		wg.Add(1)
+		go func(command ssmTypes.Command) {
+			semaphore <- struct{}{}
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			data := SSMCommandData{
+				CommandID:       aws.ToString(command.CommandId),
+				DocumentName:    aws.ToString(command.DocumentName),
+				DocumentVersion: aws.ToString(command.DocumentVersion),
+				RequestedAt:     aws.ToTime(command.RequestedDateTime),
+				Parameters:      command.Parameters,
+			}
+			invocationPaginator := ssm.NewListCommandInvocationsPaginator(ssmClient, &ssm.ListCommandInvocationsInput{
+				CommandId: command.CommandId,
+				Details:   true,
+			})
+			for invocationPaginator.HasMorePages() {
+				page, err := invocationPaginator.NextPage(ctx)
+				if err != nil {
+					log.Printf("Failed to list invocations for command %s: %v", data.CommandID, err)
+					break
+				}
+				for _, invocation := range page.CommandInvocations {
+					outputs := make(map[string]string)
+					for _, plugin := range invocation.CommandPlugins {
+						if output := aws.ToString(plugin.Output); output != "" {
+							outputs[aws.ToString(plugin.Name)] = output
+						}
+					}
+					data.Invocations = append(data.Invocations, SSMCommandInvocation{
+						InstanceID: aws.ToString(invocation.InstanceId),
+						Outputs:    outputs,
+					})
+				}
+			}
+
+			mu.Lock()
+			result = append(result, data)
+			mu.Unlock()
+		}(command)
+	}
+
+	wg.Wait()
+	return result, nil
+}
+
+func ProcessSSMDocuments(documents []SSMDocumentData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(documents, func(i, j int) bool {
+		return documents[i].Name < documents[j].Name
+	})
+
+	for _, document := range documents {
+		// Versions are sorted newest first, identical content is scanned once and reported with every version carrying it
+		var unique []SSMDocumentVersion
+		versionsByContent := make(map[string][]string)
+		for _, version := range document.Versions {
+			label := version.Version
+			if version.IsDefault {
+				label += " (default)"
+			}
+			if _, ok := versionsByContent[version.Content]; !ok {
+				unique = append(unique, version)
+			}
+			versionsByContent[version.Content] = append(versionsByContent[version.Content], label)
+		}
+
+		var printed bool
+		for _, version := range unique {
+			versions := versionsByContent[version.Content]
+			matches := matchSSMDocument(document.Name, version.Format, version.Content, patternMatcher, matchMode)
+			if len(matches) == 0 {
+				continue
+			}
+			if !printed {
+				formatting.Title("SSM Document", document.Name)
+				formatting.Data("Type", document.DocumentType)
+				printed = true
+			}
+			versionLabel := versions[0]
+			if len(versions) > 1 {
+				versionLabel += ", same content as " + strings.Join(versions[1:], ", ")
+			}
+			formatting.Data("Version", versionLabel)
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		if printed {
+			fmt.Println()
+		}
+	}
+}
+
+// matchSSMDocument scans the document as a structured file, so parameter defaults go through the key-name heuristics,
+// and the shell scripts of its runCommand/commands steps with the shell detector, each secret being reported once
+func matchSSMDocument(name string, format string, content string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	extension := ".txt"
+	switch ssmTypes.DocumentFormat(format) {
+	case ssmTypes.DocumentFormatJson:
+		extension = ".json"
+	case ssmTypes.DocumentFormatYaml:
+		extension = ".yaml"
+	}
+	file := SourceFile{Path: name + extension, Content: content}
+	defaults := documentParameterDefaults(content)
+	if defaults == nil {
+		return matchSourceFile(file, patternMatcher, matchMode)
+	}
+
+	// The line rules are replaced by the parameter default and shell checks below, which would report the same secrets
+	matches := matchFileFindings(file, patternMatcher.MatchPatternsExcept(content, matchMode, pattern.ShellSupersededRules), patternMatcher)
+	for name, value := range defaults {
+		if len(patternMatcher.MatchPatterns(value, matchMode)) > 0 {
+			// Already reported by the pattern scan of the document
+			continue
+		}
+		for patternName, matchedStrings := range patternMatcher.MatchKeyValue("parameter "+name+" default", name, value) {
+			matches[patternName] = append(matches[patternName], matchedStrings...)
+		}
+	}
+	for _, script := range documentScripts(content) {
+		for patternName, matchedStrings := range patternMatcher.MatchShell(script.Content) {
+			key := fmt.Sprintf("%s: %s", script.Path, patternName)
+			matches[key] = append(matches[key], matchedStrings...)
+		}
+	}
+	return matches
+}
+
+// documentParameterDefaults returns the string default values of the document parameters, whose names are checked
+// with the key-name heuristics since the structured scan only sees the "default" key
+func documentParameterDefaults(content string) map[string]string {
+	var document struct {
+		Parameters map[string]struct {
+			Default interface{} `yaml:"default"`
+		} `yaml:"parameters"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return nil
+	}
+	defaults := make(map[string]string)
+	for name, parameter := range document.Parameters {
+		if value, ok := parameter.Default.(string); ok {
+			defaults[name] = value
+		}
+	}
+	return defaults
+}
+
+// documentScripts extracts the runCommand and commands lists of schema 1.2 plugins, schema 2.2 steps and Automation
+// steps as shell scripts named after the step, PowerShell steps are left to the pattern scan of the document
+func documentScripts(content string) []SourceFile {
+	var document interface{}
+	if err := yaml.Unmarshal([]byte(content), &document); err != nil {
+		return nil
+	}
+	var scripts []SourceFile
+	var walk func(node interface{}, location string, action string)
+	walk = func(node interface{}, location string, action string) {
+		switch value := node.(type) {
+		case map[string]interface{}:
+			if name, ok := value["name"].(string); ok {
+				location = name
+			}
+			if stepAction, ok := value["action"].(string); ok {
+				action = stepAction
+			}
+			for _, key := range sortedKeys(value) {
+				child := value[key]
+				if key == "runCommand" || key == "commands" {
+					if lines, ok := stringList(child); ok {
+						if !strings.Contains(strings.ToLower(action), "powershell") {
+							scripts = append(scripts, SourceFile{Path: location + " " + key, Content: strings.Join(lines, "\n")})
+						}
+						continue
+					}
+				}
+				if strings.HasPrefix(key, "aws:") {
+					walk(child, key, key)
+				} else {
+					walk(child, location, action)
+				}
+			}
+		case []interface{}:
+			for _, item := range value {
+				walk(item, location, action)
+			}
+		}
+	}
+	walk(document, "document", "")
+	return scripts
+}
+
+func stringList(value interface{}) ([]string, bool) {
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, false
+	}
+	lines := make([]string, 0, len(items))
+	for _, item := range items {
+		line, ok := item.(string)
+		if !ok {
+			return nil, false
+		}
+		lines = append(lines, line)
+	}
+	return lines, true
+}
+
+func ProcessSSMCommands(commands []SSMCommandData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(commands, func(i, j int) bool {
+		return commands[i].RequestedAt.After(commands[j].RequestedAt)
+	})
+
+	for _, command := range commands {
+		parameterMatches := matchCommandParameters(command.Parameters, patternMatcher, matchMode)
+
+		var instanceMatches []map[string][]string
+		var instances []string
+		for _, invocation := range command.Invocations {
+			matches := make(map[string][]string)
+			for plugin, output := range invocation.Outputs {
+				for patternName, matchedStrings := range patternMatcher.MatchPatterns(output, matchMode) {
+					key := fmt.Sprintf("%s output: %s", plugin, patternName)
+					matches[key] = append(matches[key], matchedStrings...)
+				}
+			}
+			if len(matches) > 0 {
+				instanceMatches = append(instanceMatches, matches)
+				instances = append(instances, invocation.InstanceID)
+			}
+		}
+
+		if len(parameterMatches) == 0 && len(instanceMatches) == 0 {
+			continue
+		}
+
+		formatting.Title("SSM Command", command.CommandID)
+		document := command.DocumentName
+		if command.DocumentVersion != "" {
+			document += " (version " + command.DocumentVersion + ")"
+		}
+		formatting.Data("Document", document)
+		formatting.Data("Requested", command.RequestedAt.Format(time.RFC3339))
+		if len(parameterMatches) > 0 {
+			formatting.FuncCodeDetails("", parameterMatches, showContent)
+		}
+		for i, matches := range instanceMatches {
+			formatting.Data("Instance", instances[i])
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		fmt.Println()
+	}
+}
+
+// matchCommandParameters scans the commands parameter of AWS-RunShellScript and similar documents as a shell script,
+// and other parameters with the pattern set, or when nothing matched the key-name heuristics
+func matchCommandParameters(parameters map[string][]string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	matches := make(map[string][]string)
+	for name, values := range parameters {
+		value := strings.Join(values, "\n")
+		var found map[string][]string
+		if name == "commands" {
+			found = patternMatcher.MatchShellScript(value, matchMode)
+		} else {
+			found = matchValueOrName(name, name, values, patternMatcher, matchMode)
+		}
+		for patternName, matchedStrings := range found {
+			key := fmt.Sprintf("parameter %s: %s", name, patternName)
+			matches[key] = append(matches[key], matchedStrings...)
+		}
+	}
+	return matches
+}
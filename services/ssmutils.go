@@ -0,0 +1,229 @@
+package services
+
+import (
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+type SSMParameterData struct {
+	Name    string
+	Type    string
+	Version int64
+	Value   string
+	History []SSMParameterVersion
+}
+
+// SSMParameterVersion is a previous plaintext version of a parameter, a parameter converted to SecureString
+// keeps its String versions in the history
+type SSMParameterVersion struct {
+	Version      int64
+	Type         string
+	Value        string
+	LastModified time.Time
+}
+
+// getParametersBatchSize is the maximum number of names accepted by GetParameters
+const getParametersBatchSize = 10
+
+// FetchSSMParameters returns the plaintext (String and StringList) parameters with their values and, optionally,
+// the plaintext versions in the history of every parameter. SecureString values are never decrypted
+func FetchSSMParameters(ctx context.Context, ssmClient *ssm.Client, threads int, history bool) ([]SSMParameterData, error) {
+	parameters := make(map[string]*SSMParameterData)
+	var names []string
+	paginator := ssm.NewDescribeParametersPaginator(ssmClient, &ssm.DescribeParametersInput{})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, metadata := range page.Parameters {
+			name := aws.ToString(metadata.Name)
+			names = append(names, name)
+			parameters[name] = &SSMParameterData{Name: name, Type: string(metadata.Type), Version: metadata.Version}
+		}
+	}
+
+	// Values of the hierarchy under "/" in bulk, names without a leading slash are fetched by name below
+	byPath := ssm.NewGetParametersByPathPaginator(ssmClient, &ssm.GetParametersByPathInput{
+		Path:           aws.String("/"),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(false),
+	})
+	fetched := make(map[string]bool)
+	for byPath.HasMorePages() {
+		page, err := byPath.NextPage(ctx)
+		if err != nil {
+			log.Printf("Failed to get parameters by path: %v", err)
+			break
+		}
+		for _, parameter := range page.Parameters {
+			setParameterValue(parameters, parameter)
+			fetched[aws.ToString(parameter.Name)] = true
+		}
+	}
+
+	var missing []string
+	for _, name := range names {
+		if !fetched[name] && parameters[name].Type != string(ssmTypes.ParameterTypeSecureString) {
+			missing = append(missing, name)
+		}
+	}
+	for start := 0; start < len(missing); start += getParametersBatchSize {
+		end := start + getParametersBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		output, err := ssmClient.GetParameters(ctx, &ssm.GetParametersInput{
+			Names:          missing[start:end],
+			WithDecryption: aws.Bool(false),
+		})
+		if err != nil {
+			log.Printf("Failed to get parameters %s: %v", strings.Join(missing[start:end], ", "), err)
+			continue
+		}
+		for _, parameter := range output.Parameters {
+			setParameterValue(parameters, parameter)
+		}
+	}
+
+	if history {
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		semaphore := make(chan struct{}, threads)
+		for _, name := range names {
+			wg.Add(1)
+			go func(parameter *SSMParameterData) {
+				semaphore <- struct{}{}
+				defer func() {
+					<-semaphore
+					wg.Done()
+				}()
+				versions, err := fetchParameterHistory(ctx, ssmClient, parameter)
+				if err != nil {
+					log.Printf("Failed to get history for parameter %s: %v", parameter.Name, err)
+					return
+				}
+				mu.Lock()
+				parameter.History = versions
+				mu.Unlock()
+			}(parameters[name])
+		}
+		wg.Wait()
+	}
+
+	result := make([]SSMParameterData, 0, len(names))
+	for _, name := range names {
+		result = append(result, *parameters[name])
+	}
+	return result, nil
+}
+
+func setParameterValue(parameters map[string]*SSMParameterData, parameter ssmTypes.Parameter) {
+	if parameter.Type == ssmTypes.ParameterTypeSecureString {
+		return
+	}
+	name := aws.ToString(parameter.Name)
+	data, ok := parameters[name]
+	if !ok {
+		data = &SSMParameterData{Name: name}
+		parameters[name] = data
+	}
+	data.Type = string(parameter.Type)
+	data.Version = parameter.Version
+	data.Value = aws.ToString(parameter.Value)
+}
+
+// fetchParameterHistory returns the plaintext versions older than the current one
+func fetchParameterHistory(ctx context.Context, ssmClient *ssm.Client, parameter *SSMParameterData) ([]SSMParameterVersion, error) {
+	var versions []SSMParameterVersion
+	paginator := ssm.NewGetParameterHistoryPaginator(ssmClient, &ssm.GetParameterHistoryInput{
+		Name:           aws.String(parameter.Name),
+		WithDecryption: aws.Bool(false),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return versions, err
+		}
+		for _, version := range page.Parameters {
+			if version.Type == ssmTypes.ParameterTypeSecureString || version.Version == parameter.Version {
+				continue
+			}
+			versions = append(versions, SSMParameterVersion{
+				Version:      version.Version,
+				Type:         string(version.Type),
+				Value:        aws.ToString(version.Value),
+				LastModified: aws.ToTime(version.LastModifiedDate),
+			})
+		}
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Version > versions[j].Version
+	})
+	return versions, nil
+}
+
+func ProcessSSMParameters(parameters []SSMParameterData, patternMatcher *pattern.Patterns, showContent bool, matchMode string) {
+	sort.SliceStable(parameters, func(i, j int) bool {
+		return parameters[i].Name < parameters[j].Name
+	})
+
+	for _, parameter := range parameters {
+		var currentMatches map[string][]string
+		if parameter.Type != string(ssmTypes.ParameterTypeSecureString) {
+			currentMatches = matchParameterValue(parameter.Name, parameter.Type, parameter.Value, patternMatcher, matchMode)
+		}
+
+		var historyMatches []map[string][]string
+		var historyVersions []SSMParameterVersion
+		for _, version := range parameter.History {
+			// A value still current is reported once
+			if version.Value == parameter.Value && parameter.Type != string(ssmTypes.ParameterTypeSecureString) {
+				continue
+			}
+			if matches := matchParameterValue(parameter.Name, version.Type, version.Value, patternMatcher, matchMode); len(matches) > 0 {
+				historyMatches = append(historyMatches, matches)
+				historyVersions = append(historyVersions, version)
+			}
+		}
+
+		if len(currentMatches) == 0 && len(historyMatches) == 0 {
+			continue
+		}
+
+		formatting.Title("SSM Parameter", parameter.Name)
+		formatting.Data("Type", parameter.Type)
+		if len(currentMatches) > 0 {
+			formatting.Data("Version", strconv.FormatInt(parameter.Version, 10)+" (current)")
+			formatting.FuncCodeDetails("", currentMatches, showContent)
+		}
+		for i, matches := range historyMatches {
+			version := historyVersions[i]
+			formatting.Data("Version", fmt.Sprintf("%d (history, %s, %s)", version.Version, version.Type, version.LastModified.Format("2006-01-02")))
+			formatting.FuncCodeDetails("", matches, showContent)
+		}
+		fmt.Println()
+	}
+}
+
+// matchParameterValue scans a plaintext value with the pattern set, or when nothing matched flags values whose
+// parameter name says they should have been stored as a SecureString
+func matchParameterValue(name string, parameterType string, value string, patternMatcher *pattern.Patterns, matchMode string) map[string][]string {
+	values := []string{value}
+	if parameterType == string(ssmTypes.ParameterTypeStringList) {
+		values = strings.Split(value, ",")
+	}
+	return matchValueOrName("unencrypted "+parameterType, name, values, patternMatcher, matchMode)
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+
+	"awsecrets/formatting"
+	"awsecrets/pattern"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultUserDataPart = "user-data"
+
+// UserDataSection is a piece of decoded user data along with the MIME part and cloud-init key it came from
+type UserDataSection struct {
+	Part    string
+	Key     string
+	Content string
+}
+
+type userDataMatch struct {
+	Section UserDataSection
+	Matches map[string][]string
+}
+
+// ParseUserData splits EC2 user data into sections, unpacking gzip, MIME multipart documents and #cloud-config
+func ParseUserData(userData string) []UserDataSection {
+	if userData == "" {
+		return nil
+	}
+	return parseUserDataPart(defaultUserDataPart, "", []byte(userData))
+}
+
+func parseUserDataPart(name string, contentType string, body []byte) []UserDataSection {
+	if bytes.HasPrefix(body, []byte{0x1f, 0x8b}) {
+		unzipped, err := gunzip(body)
+		if err == nil {
+			body = unzipped
+		}
+	}
+
+	text := string(body)
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/") || isMIMEDocument(trimmed):
+		if sections, err := parseMultipartUserData(name, body); err == nil {
+			return sections
+		}
+	case mediaType == "text/cloud-config" || strings.HasPrefix(trimmed, "#cloud-config"):
+		return parseCloudConfig(name, text)
+	case mediaType == "text/x-include-url" || mediaType == "text/x-include-once-url" ||
+		strings.HasPrefix(trimmed, "#include"):
+		return parseIncludeURL(name, text)
+	}
+
+	return []UserDataSection{{Part: name, Content: text}}
+}
+
+func isMIMEDocument(text string) bool {
+	header := strings.ToLower(text)
+	return strings.HasPrefix(header, "content-type: multipart/") || strings.HasPrefix(header, "mime-version:")
+}
+
+func parseMultipartUserData(name string, body []byte) ([]UserDataSection, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, fmt.Errorf("user data %s is not a multipart document", name)
+	}
+
+	var sections []UserDataSection
+	reader := multipart.NewReader(msg.Body, params["boundary"])
+	for index := 1; ; index++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		partBody, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		partBody = decodeTransferEncoding(part.Header.Get("Content-Transfer-Encoding"), partBody)
+
+		partName := part.FileName()
+		if partName == "" {
+			partName = fmt.Sprintf("part-%d", index)
+		}
+		if name != defaultUserDataPart {
+			partName = name + "/" + partName
+		}
+		sections = append(sections, parseUserDataPart(partName, part.Header.Get("Content-Type"), partBody)...)
+	}
+	return sections, nil
+}
+
+func decodeTransferEncoding(encoding string, body []byte) []byte {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+		if err == nil {
+			return decoded
+		}
+	case "quoted-printable":
+		decoded, err := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(body)))
+		if err == nil {
+			return decoded
+		}
+	}
+	return body
+}
+
+func parseIncludeURL(name string, text string) []UserDataSection {
+	var sections []UserDataSection
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sections = append(sections, UserDataSection{Part: name, Key: "include-url", Content: line})
+	}
+	return sections
+}
+
+// parseCloudConfig breaks a #cloud-config document down by the keys cloud-init uses to carry secrets
+func parseCloudConfig(name string, text string) []UserDataSection {
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal([]byte(text), &doc); err != nil || doc == nil {
+		return []UserDataSection{{Part: name, Key: "cloud-config", Content: text}}
+	}
+
+	var sections []UserDataSection
+	add := func(key string, content string) {
+		if content != "" {
+			sections = append(sections, UserDataSection{Part: name, Key: key, Content: content})
+		}
+	}
+
+	keys := make([]string, 0, len(doc))
+	for key := range doc {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := doc[key]
+		switch key {
+		case "write_files":
+			for i, entry := range asList(value) {
+				file, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				path := asString(file["path"])
+				if path == "" {
+					path = fmt.Sprintf("%d", i)
+				}
+				add(fmt.Sprintf("write_files[%s]", path), decodeWriteFile(asString(file["content"]), asString(file["encoding"])))
+			}
+		case "runcmd", "bootcmd":
+			for i, entry := range asList(value) {
+				if args, ok := entry.([]interface{}); ok {
+					parts := make([]string, 0, len(args))
+					for _, arg := range args {
+						parts = append(parts, asString(arg))
+					}
+					add(fmt.Sprintf("%s[%d]", key, i), strings.Join(parts, " "))
+				} else {
+					add(fmt.Sprintf("%s[%d]", key, i), asString(entry))
+				}
+			}
+		case "users":
+			for i, entry := range asList(value) {
+				user, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				userName := asString(user["name"])
+				if userName == "" {
+					userName = fmt.Sprintf("%d", i)
+				}
+				for _, field := range []string{"passwd", "hashed_passwd", "plain_text_passwd"} {
+					add(fmt.Sprintf("users[%s].%s", userName, field), asString(user[field]))
+				}
+			}
+		case "chpasswd":
+			chpasswd, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch list := chpasswd["list"].(type) {
+			case string:
+				add("chpasswd.list", list)
+			case []interface{}:
+				for i, entry := range list {
+					add(fmt.Sprintf("chpasswd.list[%d]", i), asString(entry))
+				}
+			}
+			for i, entry := range asList(chpasswd["users"]) {
+				user, ok := entry.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				userName := asString(user["name"])
+				if userName == "" {
+					userName = fmt.Sprintf("%d", i)
+				}
+				add(fmt.Sprintf("chpasswd.users[%s]", userName), asString(user["password"]))
+			}
+		default:
+			if s, ok := value.(string); ok {
+				add(key, s)
+				continue
+			}
+			out, err := yaml.Marshal(value)
+			if err == nil {
+				add(key, string(out))
+			}
+		}
+	}
+	return sections
+}
+
+func decodeWriteFile(content string, encoding string) string {
+	data := []byte(content)
+	switch strings.ToLower(encoding) {
+	case "b64", "base64":
+		if decoded, err := base64.StdEncoding.DecodeString(content); err == nil {
+			data = decoded
+		}
+	case "gz", "gzip":
+		if unzipped, err := gunzip(data); err == nil {
+			data = unzipped
+		}
+	case "gz+b64", "gz+base64", "gzip+b64", "gzip+base64":
+		if decoded, err := base64.StdEncoding.DecodeString(content); err == nil {
+			if unzipped, err := gunzip(decoded); err == nil {
+				data = unzipped
+			}
+		}
+	}
+	return string(data)
+}
+
+func gunzip(data []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+func asList(value interface{}) []interface{} {
+	list, _ := value.([]interface{})
+	return list
+}
+
+func asString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func matchUserData(userData string, patternMatcher *pattern.Patterns, matchMode string) []userDataMatch {
+	var results []userDataMatch
+	for _, section := range ParseUserData(userData) {
+		var matches map[string][]string
+		if isShellSection(section) {
+			matches = patternMatcher.MatchShellScript(section.Content, matchMode)
+		} else {
+			matches = patternMatcher.MatchPatterns(section.Content, matchMode)
+		}
+		if len(matches) > 0 {
+			results = append(results, userDataMatch{Section: section, Matches: matches})
+		}
+	}
+	return results
+}
+
+// isShellSection reports whether a user data section holds shell commands (scripts, runcmd and bootcmd entries)
+func isShellSection(section UserDataSection) bool {
+	if strings.HasPrefix(section.Key, "runcmd[") || strings.HasPrefix(section.Key, "bootcmd[") {
+		return true
+	}
+	if strings.HasPrefix(section.Key, "write_files[") {
+		return pattern.IsShellScript(strings.TrimSuffix(strings.TrimPrefix(section.Key, "write_files["), "]"), section.Content)
+	}
+	return section.Key == "" && pattern.IsShellScript(section.Part, section.Content)
+}
+
+func printUserDataMatches(results []userDataMatch, showContent bool) {
+	for _, result := range results {
+		formatting.Data("User Data Part", result.Section.Part)
+		if result.Section.Key != "" {
+			formatting.Data("Cloud-init Key", result.Section.Key)
+		}
+		for _, patternName := range formatting.SortedPatterns(result.Matches) {
+			matchedStrings := result.Matches[patternName]
+			for _, match := range matchedStrings {
+				formatting.PatterName(patternName)
+				formatting.Content(match, showContent)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+func gzipString(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestParseUserData(t *testing.T) {
+	multipart := "Content-Type: multipart/mixed; boundary=\"XYZ\"\r\n" +
+		"MIME-Version: 1.0\r\n\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/x-shellscript\r\n" +
+		"Content-Disposition: attachment; filename=\"setup.sh\"\r\n\r\n" +
+		"#!/bin/bash\necho hello\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/cloud-config\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		base64.StdEncoding.EncodeToString([]byte("#cloud-config\nruncmd:\n  - echo one\n")) + "\r\n" +
+		"--XYZ--\r\n"
+
+	tests := []struct {
+		name     string
+		userData string
+		want     []UserDataSection
+	}{
+		{
+			name:     "empty",
+			userData: "",
+			want:     nil,
+		},
+		{
+			name:     "plain script",
+			userData: "#!/bin/bash\necho hello\n",
+			want:     []UserDataSection{{Part: "user-data", Content: "#!/bin/bash\necho hello\n"}},
+		},
+		{
+			name:     "multipart with base64 cloud-config part",
+			userData: multipart,
+			want: []UserDataSection{
+				{Part: "setup.sh", Content: "#!/bin/bash\necho hello"},
+				{Part: "part-2", Key: "runcmd[0]", Content: "echo one"},
+			},
+		},
+		{
+			name:     "include url",
+			userData: "#include\n# comment\nhttps://example.com/a.sh\n\nhttps://example.com/b.sh\n",
+			want: []UserDataSection{
+				{Part: "user-data", Key: "include-url", Content: "https://example.com/a.sh"},
+				{Part: "user-data", Key: "include-url", Content: "https://example.com/b.sh"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseUserData(tt.userData); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseUserData() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		got := ParseUserData(gzipString(t, "#!/bin/sh\nexport A=1\n"))
+		want := []UserDataSection{{Part: "user-data", Content: "#!/bin/sh\nexport A=1\n"}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseUserData() = %#v, want %#v", got, want)
+		}
+	})
+}
+
+func TestParseCloudConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []UserDataSection
+	}{
+		{
+			name: "runcmd string and list entries",
+			text: "#cloud-config\nruncmd:\n  - echo one\n  - [mysql, -u, root, -psecret]\n",
+			want: []UserDataSection{
+				{Part: "p", Key: "runcmd[0]", Content: "echo one"},
+				{Part: "p", Key: "runcmd[1]", Content: "mysql -u root -psecret"},
+			},
+		},
+		{
+			name: "write_files with encodings",
+			text: "#cloud-config\nwrite_files:\n  - path: /etc/app.env\n    encoding: b64\n    content: " +
+				base64.StdEncoding.EncodeToString([]byte("DB_PASSWORD=x")) + "\n  - content: plain\n",
+			want: []UserDataSection{
+				{Part: "p", Key: "write_files[/etc/app.env]", Content: "DB_PASSWORD=x"},
+				{Part: "p", Key: "write_files[1]", Content: "plain"},
+			},
+		},
+		{
+			name: "users and chpasswd",
+			text: "#cloud-config\nusers:\n  - name: admin\n    plain_text_passwd: hunter2\nchpasswd:\n  list: |\n    root:toor\n  users:\n    - name: bob\n      password: b0b\n",
+			want: []UserDataSection{
+				{Part: "p", Key: "chpasswd.list", Content: "root:toor\n"},
+				{Part: "p", Key: "chpasswd.users[bob]", Content: "b0b"},
+				{Part: "p", Key: "users[admin].plain_text_passwd", Content: "hunter2"},
+			},
+		},
+		{
+			name: "other keys are kept as YAML",
+			text: "#cloud-config\nhostname: web\nntp:\n  enabled: true\n",
+			want: []UserDataSection{
+				{Part: "p", Key: "hostname", Content: "web"},
+				{Part: "p", Key: "ntp", Content: "enabled: true\n"},
+			},
+		},
+		{
+			name: "invalid YAML is kept whole",
+			text: "#cloud-config\n: [\n",
+			want: []UserDataSection{{Part: "p", Key: "cloud-config", Content: "#cloud-config\n: [\n"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCloudConfig("p", tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCloudConfig() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeWriteFile(t *testing.T) {
+	gz := gzipString(t, "secret")
+	tests := []struct {
+		name     string
+		content  string
+		encoding string
+		want     string
+	}{
+		{"plain", "secret", "", "secret"},
+		{"base64", base64.StdEncoding.EncodeToString([]byte("secret")), "b64", "secret"},
+		{"gzip", gz, "gzip", "secret"},
+		{"gzip and base64", base64.StdEncoding.EncodeToString([]byte(gz)), "gz+b64", "secret"},
+		{"invalid base64 is kept", "not base64!", "base64", "not base64!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeWriteFile(tt.content, tt.encoding); got != tt.want {
+				t.Errorf("decodeWriteFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsShellSection(t *testing.T) {
+	tests := []struct {
+		name    string
+		section UserDataSection
+		want    bool
+	}{
+		{"runcmd", UserDataSection{Part: "user-data", Key: "runcmd[0]", Content: "echo"}, true},
+		{"bootcmd", UserDataSection{Part: "user-data", Key: "bootcmd[1]", Content: "echo"}, true},
+		{"shell write_files", UserDataSection{Part: "user-data", Key: "write_files[/opt/run.sh]", Content: "echo"}, true},
+		{"env write_files", UserDataSection{Part: "user-data", Key: "write_files[/etc/app.env]", Content: "A=1"}, false},
+		{"script part", UserDataSection{Part: "user-data", Content: "#!/bin/bash\necho"}, true},
+		{"users", UserDataSection{Part: "user-data", Key: "users[admin].passwd", Content: "x"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isShellSection(tt.section); got != tt.want {
+				t.Errorf("isShellSection() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}